@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// The call hierarchy shows the lineage of a model, its outgoing calls are the
+// models and sources it selects from and its incoming calls the models built on it
+
+func (w *Workspace) prepareCallHierarchyHandler(context *glsp.Context, params *protocol.CallHierarchyPrepareParams) ([]protocol.CallHierarchyItem, error) {
+	hierarchyLog := commonlog.GetLoggerf("%s.callHierarchy", lsName)
+	manifest := w.Manifest()
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		hierarchyLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	key := getLineageKeyAtPosition(manifest, params.TextDocument.URI, string(fileContent), params.Position)
+	item, ok := getCallHierarchyItem(manifest, key)
+	if !ok {
+		hierarchyLog.Infof("could not find %v", key)
+		return nil, nil
+	}
+
+	return []protocol.CallHierarchyItem{item}, nil
+}
+
+func (w *Workspace) incomingCallsHandler(context *glsp.Context, params *protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	key, ok := params.Item.Data.(string)
+	if !ok {
+		return nil, nil
+	}
+	return getIncomingCalls(w.Manifest(), w.Documents, key), nil
+}
+
+func (w *Workspace) outgoingCallsHandler(context *glsp.Context, params *protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	key, ok := params.Item.Data.(string)
+	if !ok {
+		return nil, nil
+	}
+	return getOutgoingCalls(w.Manifest(), w.Documents, key), nil
+}
+
+// getLineageKeyAtPosition returns the source under the cursor, otherwise the
+// model referenced there or the model the file defines
+func getLineageKeyAtPosition(manifest Manifest, uri, content string, position protocol.Position) string {
+	parser := NewJinjaParser()
+	rawPosition := getRawPositionInFile(content, position.Line, position.Character)
+
+	for _, tag := range parser.GetAllSourceTags(content) {
+		if rawPosition >= tag.Range.Start && rawPosition <= tag.Range.End {
+			return getSourceKey(manifest, tag)
+		}
+	}
+
+	return getModelKeyAtPosition(manifest, uri, content, position)
+}
+
+func getCallHierarchyItem(manifest Manifest, key string) (protocol.CallHierarchyItem, bool) {
+	detail := key
+	if source, ok := manifest.Sources[key]; ok {
+		return protocol.CallHierarchyItem{
+			Name:           fmt.Sprintf("%s.%s", source.SourceName, source.Name),
+			Kind:           protocol.SymbolKindStruct,
+			Detail:         &detail,
+			URI:            source.OriginalPath,
+			Range:          source.Range,
+			SelectionRange: source.NameRange,
+			Data:           key,
+		}, true
+	}
+
+	node, ok := manifest.Nodes[key]
+	if !ok {
+		return protocol.CallHierarchyItem{}, false
+	}
+
+	return protocol.CallHierarchyItem{
+		Name:           node.Name,
+		Kind:           getNodeSymbolKind(key),
+		Detail:         &detail,
+		URI:            node.OriginalPath,
+		Range:          node.Range,
+		SelectionRange: protocol.Range{Start: node.Range.Start, End: node.Range.Start},
+		Data:           key,
+	}, true
+}
+
+// getIncomingCalls returns the nodes that depend on key with the ref() or
+// source() tags in them that point at it
+func getIncomingCalls(manifest Manifest, documents *DocumentStore, key string) []protocol.CallHierarchyIncomingCall {
+	calls := []protocol.CallHierarchyIncomingCall{}
+
+	for _, child := range getChildMap(manifest)[key] {
+		item, ok := getCallHierarchyItem(manifest, child)
+		if !ok {
+			continue
+		}
+
+		calls = append(calls, protocol.CallHierarchyIncomingCall{
+			From:       item,
+			FromRanges: getCallRanges(manifest, documents, item.URI, key),
+		})
+	}
+	return calls
+}
+
+// getOutgoingCalls returns what the node with key depends on, with the tags in
+// its file that point at each of them
+func getOutgoingCalls(manifest Manifest, documents *DocumentStore, key string) []protocol.CallHierarchyOutgoingCall {
+	calls := []protocol.CallHierarchyOutgoingCall{}
+	node, ok := manifest.Nodes[key]
+	if !ok {
+		return calls
+	}
+
+	seen := map[string]bool{}
+	for _, parent := range node.Depends.Nodes {
+		if seen[parent] {
+			continue
+		}
+		seen[parent] = true
+
+		item, ok := getCallHierarchyItem(manifest, parent)
+		if !ok {
+			continue
+		}
+
+		calls = append(calls, protocol.CallHierarchyOutgoingCall{
+			To:         item,
+			FromRanges: getCallRanges(manifest, documents, node.OriginalPath, parent),
+		})
+	}
+	return calls
+}
+
+// getCallRanges returns the ranges of the ref() and source() tags in the file at
+// uri that point at key
+func getCallRanges(manifest Manifest, documents *DocumentStore, uri, key string) []protocol.Range {
+	ranges := []protocol.Range{}
+	fileContent, err := documents.ReadFile(uri)
+	if err != nil {
+		return ranges
+	}
+
+	content := string(fileContent)
+	parser := NewJinjaParser()
+	for _, ref := range parser.GetAllRefTags(content) {
+		if manifest.GetRefKey(ref) == key {
+			ranges = append(ranges, getRangeInFile(content, ref.Range))
+		}
+	}
+	for _, tag := range parser.GetAllSourceTags(content) {
+		if getSourceKey(manifest, tag) == key {
+			ranges = append(ranges, getRangeInFile(content, tag.Range))
+		}
+	}
+
+	slices.SortFunc(ranges, func(a, b protocol.Range) int {
+		if a.Start.Line != b.Start.Line {
+			return int(a.Start.Line) - int(b.Start.Line)
+		}
+		return int(a.Start.Character) - int(b.Start.Character)
+	})
+	return ranges
+}
+
+func getSourceKey(manifest Manifest, tag SourceReference) string {
+	if key, _, ok := manifest.FindSource(tag.SourceName, tag.TableName); ok {
+		return key
+	}
+	return fmt.Sprintf("source.%v.%v.%v", manifest.Metadata.ProjectName, tag.SourceName, tag.TableName)
+}
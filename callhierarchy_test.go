@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestCallHierarchy(t *testing.T) {
+	settings, _ := LoadSettings("./tests/project")
+	manifest, err := settings.BuildManifest()
+	if err != nil {
+		t.Fatalf("could not build manifest %v", err)
+	}
+
+	documents := NewDocumentStore()
+	ordersUri := "file://" + filepath.Join(settings.GetRootDirectory(), "models", "orders.sql")
+	documents.Open(ordersUri, "select *\nfrom {{ ref('stg_orders') }}\njoin {{ ref('stg_orders') }} using (order_id)")
+
+	key := getLineageKeyAtPosition(manifest, ordersUri, "select *\nfrom {{ ref('stg_orders') }}", protocol.Position{Line: 1, Character: 12})
+	item, ok := getCallHierarchyItem(manifest, key)
+	if !ok || item.Name != "stg_orders" || item.Data != "model.jaffle_shop.stg_orders" {
+		t.Fatalf("expected stg_orders under the cursor but got %+v", item)
+	}
+
+	incoming := getIncomingCalls(manifest, documents, "model.jaffle_shop.stg_orders")
+	if len(incoming) != 1 || incoming[0].From.Data != "model.jaffle_shop.orders" {
+		t.Fatalf("expected orders to call stg_orders but got %+v", incoming)
+	}
+
+	ranges := incoming[0].FromRanges
+	if len(ranges) != 2 || ranges[0].Start != (protocol.Position{Line: 1, Character: 5}) || ranges[0].End != (protocol.Position{Line: 1, Character: 28}) || ranges[1].Start.Line != 2 {
+		t.Errorf("expected the ref() tags of the open buffer but got %+v", ranges)
+	}
+
+	outgoing := getOutgoingCalls(manifest, documents, "model.jaffle_shop.orders")
+	if len(outgoing) != 1 || outgoing[0].To.Data != "model.jaffle_shop.stg_orders" || len(outgoing[0].FromRanges) != 2 {
+		t.Errorf("expected orders to call stg_orders once but got %+v", outgoing)
+	}
+
+	if outgoing := getOutgoingCalls(manifest, documents, "model.jaffle_shop.stg_orders"); len(outgoing) != 0 {
+		t.Errorf("undefined sources should be left out but got %+v", outgoing)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// selectorRegex splits a dbt selector like 2+orders+ into the depth upstream, the
+// name and the depth downstream, a + without a number means all of them
+var selectorRegex = regexp.MustCompile(`^(?:(\d*)\+)?(.+?)(?:\+(\d*))?$`)
+
+var mermaidIdRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+type lineageNode struct {
+	Key          string `json:"unique_id"`
+	Name         string `json:"name"`
+	ResourceType string `json:"resource_type"`
+	Materialized string `json:"materialized,omitempty"`
+}
+
+type lineageEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// lineageGraph is the dependency graph of a project, edges go from a node to the
+// nodes built on it
+type lineageGraph struct {
+	Nodes []lineageNode `json:"nodes"`
+	Edges []lineageEdge `json:"edges"`
+}
+
+// runGraph prints the lineage of the project without running dbt, e.g.
+// dbt-lsp graph --format=mermaid --select +orders+
+func runGraph(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := flags.String("format", "dot", "output format, one of dot, mermaid or json")
+	selection := flags.String("select", "", "dbt style selectors separated by spaces, like +orders+")
+	projectDir := flags.String("project-dir", ".", "the directory holding dbt_project.yml")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	root, err := filepath.Abs(*projectDir)
+	if err != nil {
+		return err
+	}
+
+	settings, err := LoadSettings(root)
+	if err != nil {
+		return fmt.Errorf("could not load the project %w", err)
+	}
+
+	schemas, err := settings.GetSchemaFiles()
+	if err != nil {
+		return fmt.Errorf("could not load schema files %w", err)
+	}
+
+	manifest, err := settings.PredictManifestFile(settings.Name, schemas)
+	if err != nil {
+		return fmt.Errorf("could not index the project %w", err)
+	}
+
+	manifest.Sources, err = settings.GetSources()
+	if err != nil {
+		return fmt.Errorf("could not load sources %w", err)
+	}
+
+	graph := buildLineageGraph(manifest)
+	if *selection != "" {
+		graph, err = graph.Select(strings.Fields(*selection))
+		if err != nil {
+			return err
+		}
+	}
+
+	switch *format {
+	case "dot":
+		return graph.WriteDot(stdout)
+	case "mermaid":
+		return graph.WriteMermaid(stdout)
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(graph)
+	}
+	return fmt.Errorf("unknown format %q, expected dot, mermaid or json", *format)
+}
+
+// buildLineageGraph turns the depends_on of every node into edges, dependencies
+// the project doesn't define, like undeclared sources, are kept as nodes
+func buildLineageGraph(manifest Manifest) lineageGraph {
+	nodes := map[string]lineageNode{}
+	for key, node := range manifest.Nodes {
+		materialized, _ := node.Config["materialized"].(string)
+		nodes[key] = lineageNode{Key: key, Name: node.Name, ResourceType: node.ResourceType, Materialized: materialized}
+	}
+
+	for key, source := range manifest.Sources {
+		nodes[key] = lineageNode{Key: key, Name: fmt.Sprintf("%s.%s", source.SourceName, source.Name), ResourceType: "source"}
+	}
+
+	graph := lineageGraph{Nodes: []lineageNode{}, Edges: []lineageEdge{}}
+	for key, node := range manifest.Nodes {
+		for _, parent := range node.Depends.Nodes {
+			if _, ok := nodes[parent]; !ok {
+				parts := strings.SplitN(parent, ".", 3)
+				nodes[parent] = lineageNode{Key: parent, Name: parts[len(parts)-1], ResourceType: parts[0]}
+			}
+
+			edge := lineageEdge{From: parent, To: key}
+			if !slices.Contains(graph.Edges, edge) {
+				graph.Edges = append(graph.Edges, edge)
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	graph.sort()
+	return graph
+}
+
+func (g *lineageGraph) sort() {
+	slices.SortFunc(g.Nodes, func(a, b lineageNode) int { return strings.Compare(a.Key, b.Key) })
+	slices.SortFunc(g.Edges, func(a, b lineageEdge) int {
+		if a.From != b.From {
+			return strings.Compare(a.From, b.From)
+		}
+		return strings.Compare(a.To, b.To)
+	})
+}
+
+// Select keeps the nodes matched by any of the selectors. A + before the name
+// adds the parents and one after it the children, a number limits the depth
+func (g lineageGraph) Select(selectors []string) (lineageGraph, error) {
+	parents := map[string][]string{}
+	children := map[string][]string{}
+	for _, edge := range g.Edges {
+		parents[edge.To] = append(parents[edge.To], edge.From)
+		children[edge.From] = append(children[edge.From], edge.To)
+	}
+
+	selected := map[string]bool{}
+	for _, selector := range selectors {
+		match := selectorRegex.FindStringSubmatch(selector)
+		if match == nil {
+			return lineageGraph{}, fmt.Errorf("invalid selector %q", selector)
+		}
+
+		upstream, err := getSelectorDepth(selector, match[1], strings.HasPrefix(selector, match[1]+"+"))
+		if err != nil {
+			return lineageGraph{}, err
+		}
+		downstream, err := getSelectorDepth(selector, match[3], strings.HasSuffix(selector, "+"+match[3]))
+		if err != nil {
+			return lineageGraph{}, err
+		}
+
+		found := false
+		for _, node := range g.Nodes {
+			if !node.Matches(match[2]) {
+				continue
+			}
+
+			found = true
+			selected[node.Key] = true
+			for _, key := range walkLineage(parents, node.Key, upstream) {
+				selected[key] = true
+			}
+			for _, key := range walkLineage(children, node.Key, downstream) {
+				selected[key] = true
+			}
+		}
+
+		if !found {
+			return lineageGraph{}, fmt.Errorf("%q does not match any node", selector)
+		}
+	}
+
+	subgraph := lineageGraph{Nodes: []lineageNode{}, Edges: []lineageEdge{}}
+	for _, node := range g.Nodes {
+		if selected[node.Key] {
+			subgraph.Nodes = append(subgraph.Nodes, node)
+		}
+	}
+	for _, edge := range g.Edges {
+		if selected[edge.From] && selected[edge.To] {
+			subgraph.Edges = append(subgraph.Edges, edge)
+		}
+	}
+	return subgraph, nil
+}
+
+// getSelectorDepth returns how far a selector reaches on one side, 0 when it has
+// no + there and -1 when it has no limit
+func getSelectorDepth(selector, depth string, hasOperator bool) (int, error) {
+	if !hasOperator {
+		return 0, nil
+	}
+	if depth == "" {
+		return -1, nil
+	}
+
+	value, err := strconv.Atoi(depth)
+	if err != nil {
+		return 0, fmt.Errorf("invalid depth in selector %q", selector)
+	}
+	return value, nil
+}
+
+// Matches is true when the node has the name, sources are selected with
+// source:<source> or source:<source>.<table>
+func (n lineageNode) Matches(name string) bool {
+	if sourceName, ok := strings.CutPrefix(name, "source:"); ok {
+		return n.ResourceType == "source" && (n.Name == sourceName || strings.HasPrefix(n.Name, sourceName+"."))
+	}
+	return n.Name == name || n.Key == name
+}
+
+// walkLineage follows the edges from key breadth first, depth -1 has no limit
+func walkLineage(edges map[string][]string, key string, depth int) []string {
+	seen := map[string]bool{key: true}
+	found := []string{}
+	current := []string{key}
+
+	for level := 0; len(current) > 0 && (depth < 0 || level < depth); level++ {
+		next := []string{}
+		for _, node := range current {
+			for _, neighbour := range edges[node] {
+				if seen[neighbour] {
+					continue
+				}
+				seen[neighbour] = true
+				found = append(found, neighbour)
+				next = append(next, neighbour)
+			}
+		}
+		current = next
+	}
+	return found
+}
+
+func (g lineageGraph) WriteDot(w io.Writer) error {
+	var builder strings.Builder
+	builder.WriteString("digraph lineage {\n  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		shape := "box"
+		if node.ResourceType == "source" {
+			shape = "cylinder"
+		}
+		fmt.Fprintf(&builder, "  %q [label=%q, shape=%s];\n", node.Key, node.Name, shape)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&builder, "  %q -> %q;\n", edge.From, edge.To)
+	}
+	builder.WriteString("}\n")
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+func (g lineageGraph) WriteMermaid(w io.Writer) error {
+	id := func(key string) string {
+		return mermaidIdRegex.ReplaceAllString(key, "_")
+	}
+
+	var builder strings.Builder
+	builder.WriteString("graph LR\n")
+	for _, node := range g.Nodes {
+		label := strings.ReplaceAll(node.Name, `"`, "#quot;")
+		if node.ResourceType == "source" {
+			fmt.Fprintf(&builder, "  %s[(\"%s\")]\n", id(node.Key), label)
+		} else {
+			fmt.Fprintf(&builder, "  %s[\"%s\"]\n", id(node.Key), label)
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&builder, "  %s --> %s\n", id(edge.From), id(edge.To))
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
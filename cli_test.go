@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraphCommand(t *testing.T) {
+	var output bytes.Buffer
+	if err := runGraph([]string{"--format=mermaid", "--project-dir", "./tests/project", "--select", "+orders"}, &output); err != nil {
+		t.Fatalf("graph failed %v", err)
+	}
+
+	expected := `graph LR
+  model_jaffle_shop_orders["orders"]
+  model_jaffle_shop_stg_orders["stg_orders"]
+  source_jaffle_shop_raw_orders[("raw.orders")]
+  model_jaffle_shop_stg_orders --> model_jaffle_shop_orders
+  source_jaffle_shop_raw_orders --> model_jaffle_shop_stg_orders
+`
+	if output.String() != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, output.String())
+	}
+
+	output.Reset()
+	if err := runGraph([]string{"--project-dir", "./tests/project", "--select", "stg_orders+"}, &output); err != nil {
+		t.Fatalf("graph failed %v", err)
+	}
+	if !strings.Contains(output.String(), `"model.jaffle_shop.stg_orders" -> "model.jaffle_shop.orders";`) || strings.Contains(output.String(), "raw.orders") {
+		t.Errorf("expected stg_orders and its children as dot but got\n%v", output.String())
+	}
+
+	if err := runGraph([]string{"--project-dir", "./tests/project", "--format", "svg"}, &output); err == nil {
+		t.Errorf("unknown formats should fail")
+	}
+}
+
+func TestGraphSelectors(t *testing.T) {
+	graph := lineageGraph{
+		Nodes: []lineageNode{{Key: "a", Name: "a"}, {Key: "b", Name: "b"}, {Key: "c", Name: "c"}, {Key: "d", Name: "d"}},
+		Edges: []lineageEdge{{From: "a", To: "b"}, {From: "b", To: "c"}, {From: "c", To: "d"}},
+	}
+
+	tests := map[string][]string{
+		"c":     {"c"},
+		"+c":    {"a", "b", "c"},
+		"1+c":   {"b", "c"},
+		"b+":    {"b", "c", "d"},
+		"b+1":   {"b", "c"},
+		"1+b+1": {"a", "b", "c"},
+		"a d":   {"a", "d"},
+	}
+
+	for selector, expected := range tests {
+		subgraph, err := graph.Select(strings.Fields(selector))
+		if err != nil {
+			t.Fatalf("%v failed %v", selector, err)
+		}
+
+		keys := []string{}
+		for _, node := range subgraph.Nodes {
+			keys = append(keys, node.Key)
+		}
+		if strings.Join(keys, ",") != strings.Join(expected, ",") {
+			t.Errorf("%v selected %v, expected %v", selector, keys, expected)
+		}
+	}
+}
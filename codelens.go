@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// showLineageCommand returns every model and source upstream and downstream of
+// the model in its argument
+const showLineageCommand = "dbt.showLineage"
+
+var commands = []string{showLineageCommand}
+
+func (w *Workspace) codeLensHandler(context *glsp.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
+	codeLensLog := commonlog.GetLoggerf("%s.codeLens", lsName)
+	settings, manifest := w.Snapshot()
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		codeLensLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	lens, ok := getLineageCodeLens(settings, manifest, params.TextDocument.URI, string(fileContent))
+	if !ok {
+		return nil, nil
+	}
+	return []protocol.CodeLens{lens}, nil
+}
+
+func (w *Workspace) executeCommandHandler(context *glsp.Context, params *protocol.ExecuteCommandParams) (any, error) {
+	switch params.Command {
+	case showLineageCommand:
+		if len(params.Arguments) != 1 {
+			return nil, fmt.Errorf("%v expects the key of a model", showLineageCommand)
+		}
+
+		key, ok := params.Arguments[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%v expects the key of a model", showLineageCommand)
+		}
+		return getLineageLocations(w.Manifest(), key), nil
+	}
+
+	return nil, fmt.Errorf("unknown command %v", params.Command)
+}
+
+// getLineageCodeLens sits at the top of a model file and counts what the model is
+// built from and what is built on it
+func getLineageCodeLens(settings ProjectSettings, manifest Manifest, uri, content string) (protocol.CodeLens, bool) {
+	path, err := CleanUri(uri)
+	if err != nil {
+		return protocol.CodeLens{}, false
+	}
+
+	key := fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, getModelNameFromFilePath(path))
+	node, ok := manifest.Nodes[key]
+	if !ok {
+		return protocol.CodeLens{}, false
+	}
+
+	// the node config wins over dbt_project.yml as it may come from the compiled
+	// manifest, the config blocks come from the buffer so the lens follows unsaved
+	// edits
+	config := maps.Clone(settings.GetModelConfigForFile(manifest.Metadata.ProjectName, path))
+	if config == nil {
+		config = map[string]any{}
+	}
+	maps.Copy(config, node.Config)
+	config = mergeConfigBlocks(config, NewJinjaParser().GetConfigBlocks(content))
+
+	materialized, ok := config["materialized"].(string)
+	if !ok {
+		materialized = "view"
+	}
+
+	firstLine, _, _ := strings.Cut(content, "\n")
+	upstream, downstream := getLineage(manifest, key)
+	return protocol.CodeLens{
+		Range: getRangeInFile(content, Range{Start: 0, End: len(firstLine)}),
+		Command: &protocol.Command{
+			Title:     fmt.Sprintf("%d upstream · %d downstream · materialized: %s", len(upstream), len(downstream), materialized),
+			Command:   showLineageCommand,
+			Arguments: []any{key},
+		},
+	}, true
+}
+
+// getLineageLocations points at everything upstream of the model with key, then
+// everything downstream of it
+func getLineageLocations(manifest Manifest, key string) []protocol.Location {
+	upstream, downstream := getLineage(manifest, key)
+
+	locations := []protocol.Location{}
+	for _, lineageKey := range append(upstream, downstream...) {
+		item, ok := getCallHierarchyItem(manifest, lineageKey)
+		if !ok {
+			continue
+		}
+		locations = append(locations, protocol.Location{URI: item.URI, Range: item.SelectionRange})
+	}
+	return locations
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLineageCodeLens(t *testing.T) {
+	root, _ := filepath.Abs("./tests/project")
+	settings, _ := LoadSettings(root)
+	manifest, err := settings.BuildManifest()
+	if err != nil {
+		t.Fatalf("could not build manifest %v", err)
+	}
+
+	uri := "file://" + filepath.Join(settings.GetRootDirectory(), "models", "staging", "stg_orders.sql")
+	lens, ok := getLineageCodeLens(settings, manifest, uri, "select * from {{ source('raw', 'orders') }}")
+	if !ok || lens.Command.Title != "0 upstream · 1 downstream · materialized: table" {
+		t.Errorf("expected the lineage of stg_orders but got %+v", lens.Command)
+	}
+	if lens.Command.Command != showLineageCommand || lens.Command.Arguments[0] != "model.jaffle_shop.stg_orders" {
+		t.Errorf("expected the lens to show the lineage of stg_orders but got %+v", lens.Command)
+	}
+	if r := lens.Range; r.Start.Line != 0 || r.Start.Character != 0 || r.End.Line != 0 || r.End.Character != 43 {
+		t.Errorf("expected the lens on the first line but got %v", r)
+	}
+
+	// editors escape the uri, the path has to match the model paths in dbt_project.yml
+	uri = "file://" + filepath.Join(settings.GetRootDirectory(), "models", "st%61ging", "stg%5Forders.sql")
+	lens, _ = getLineageCodeLens(settings, manifest, uri, "select 1")
+	if lens.Command == nil || lens.Command.Title != "0 upstream · 1 downstream · materialized: table" {
+		t.Errorf("expected the escaped uri to find the staging config but got %+v", lens.Command)
+	}
+
+	uri = "file://" + filepath.Join(settings.GetRootDirectory(), "models", "orders.sql")
+	lens, _ = getLineageCodeLens(settings, manifest, uri, "{{ config(materialized='incremental') }}\nselect * from {{ ref('stg_orders') }}")
+	if lens.Command.Title != "1 upstream · 0 downstream · materialized: incremental" {
+		t.Errorf("expected the config block of the buffer to win but got %v", lens.Command.Title)
+	}
+
+	// the compiled manifest knows the config dbt_project.yml and macros set
+	compiled := manifest.Clone()
+	node := compiled.Nodes["model.jaffle_shop.stg_orders"]
+	node.Config = map[string]any{"materialized": "ephemeral"}
+	compiled.Nodes["model.jaffle_shop.stg_orders"] = node
+	uri = "file://" + filepath.Join(settings.GetRootDirectory(), "models", "staging", "stg_orders.sql")
+	if lens, _ = getLineageCodeLens(settings, compiled, uri, "select 1"); lens.Command.Title != "0 upstream · 1 downstream · materialized: ephemeral" {
+		t.Errorf("expected the node config to win over dbt_project.yml but got %v", lens.Command.Title)
+	}
+
+	locations := getLineageLocations(manifest, "model.jaffle_shop.orders")
+	if len(locations) != 1 || locations[0].URI != "file://"+filepath.Join(settings.GetRootDirectory(), "models", "staging", "stg_orders.sql") {
+		t.Errorf("expected stg_orders upstream of orders but got %+v", locations)
+	}
+
+	if _, ok := getLineageCodeLens(settings, manifest, "file:///elsewhere/unknown.sql", ""); ok {
+		t.Errorf("files that aren't models should not get a lens")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joro550/dbt-language-server/sql"
+)
+
+// ColumnReference is a column in the sql body resolved to the node that owns it
+type ColumnReference struct {
+	// OwnerKey is the model or source key the column belongs to
+	OwnerKey string
+	Column   NodeColumn
+	Range    Range
+}
+
+type queryRelation struct {
+	Key     string
+	Columns map[string]NodeColumn
+}
+
+// getQueryRelations finds every ref() and source() used as a relation in the query,
+// keyed by the alias it was given. Relations without an alias are keyed by ""
+func getQueryRelations(manifest Manifest, tokens []sql.Token) ([]queryRelation, map[string]queryRelation) {
+	parser := NewJinjaParser()
+	relations := []queryRelation{}
+	aliases := map[string]queryRelation{}
+
+	for i, tok := range tokens {
+		if tok.Token != sql.JINJA {
+			continue
+		}
+
+		var relation queryRelation
+		if refs := parser.GetAllRefTags(tok.Value); len(refs) == 1 {
+			key := manifest.GetRefKey(refs[0])
+			node, ok := manifest.Nodes[key]
+			if !ok {
+				continue
+			}
+			relation = queryRelation{Key: key, Columns: node.Columns}
+		} else if sources := parser.GetAllSourceTags(tok.Value); len(sources) == 1 {
+			key, source, ok := manifest.FindSource(sources[0].SourceName, sources[0].TableName)
+			if !ok {
+				continue
+			}
+			relation = queryRelation{Key: key, Columns: source.Columns}
+		} else {
+			continue
+		}
+
+		relations = append(relations, relation)
+
+		next := i + 1
+		if next < len(tokens) && tokens[next].Token == sql.KEYWORD && strings.EqualFold(tokens[next].Value, "as") {
+			next++
+		}
+		if next < len(tokens) && (tokens[next].Token == sql.IDENT || tokens[next].Token == sql.QUOTED_IDENT) {
+			aliases[strings.ToLower(unquoteIdentifier(tokens[next].Value))] = relation
+		}
+	}
+
+	return relations, aliases
+}
+
+// getColumnAtPosition resolves the column under the cursor. Qualified columns are
+// looked up through their alias, unqualified ones in every relation of the query
+// and finally in the model the file defines
+func getColumnAtPosition(manifest Manifest, nodeKey, content string, rawPosition int) (ColumnReference, bool) {
+	tokens := sql.NewSqlLexer(content).Tokenize()
+
+	index := -1
+	for i, tok := range tokens {
+		if rawPosition >= tok.Start && rawPosition <= tok.End && (tok.Token == sql.IDENT || tok.Token == sql.QUOTED_IDENT) {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return ColumnReference{}, false
+	}
+
+	tok := tokens[index]
+	columnName := unquoteIdentifier(tok.Value)
+	tokenRange := Range{Start: tok.Start, End: tok.End}
+	relations, aliases := getQueryRelations(manifest, tokens)
+
+	// the cursor is on the alias in alias.column
+	if index+1 < len(tokens) && tokens[index+1].Token == sql.DOT {
+		return ColumnReference{}, false
+	}
+
+	if index >= 2 && tokens[index-1].Token == sql.DOT {
+		relation, ok := aliases[strings.ToLower(unquoteIdentifier(tokens[index-2].Value))]
+		if !ok {
+			return ColumnReference{}, false
+		}
+
+		column, ok := findColumn(relation.Columns, columnName)
+		return ColumnReference{OwnerKey: relation.Key, Column: column, Range: tokenRange}, ok
+	}
+
+	for _, relation := range relations {
+		if column, ok := findColumn(relation.Columns, columnName); ok {
+			return ColumnReference{OwnerKey: relation.Key, Column: column, Range: tokenRange}, true
+		}
+	}
+
+	if column, ok := findColumn(manifest.Nodes[nodeKey].Columns, columnName); ok {
+		return ColumnReference{OwnerKey: nodeKey, Column: column, Range: tokenRange}, true
+	}
+
+	return ColumnReference{}, false
+}
+
+func findColumn(columns map[string]NodeColumn, name string) (NodeColumn, bool) {
+	if column, ok := columns[name]; ok {
+		return column, true
+	}
+
+	for columnName, column := range columns {
+		if strings.EqualFold(columnName, name) {
+			return column, true
+		}
+	}
+	return NodeColumn{}, false
+}
+
+func unquoteIdentifier(identifier string) string {
+	if len(identifier) < 2 {
+		return identifier
+	}
+
+	switch identifier[0] {
+	case '"', '`', '[':
+		return identifier[1 : len(identifier)-1]
+	}
+	return identifier
+}
+
+func (c ColumnReference) GetHoverText() string {
+	text := fmt.Sprintf("**%s**", c.Column.Name)
+	if c.Column.DataType != "" {
+		text += fmt.Sprintf(" `%s`", c.Column.DataType)
+	}
+	if c.Column.Description != "" {
+		text += "\n\n" + c.Column.Description
+	}
+	return text + fmt.Sprintf("\n\n_%s_", c.OwnerKey)
+}
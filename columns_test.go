@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColumnHover(t *testing.T) {
+	manifest := Manifest{
+		Metadata: Metadata{ProjectName: "test"},
+		Nodes: map[string]Node{
+			"model.test.customers": {Name: "customers", Columns: map[string]NodeColumn{
+				"id":   {Name: "id", Description: "The primary key for this table", DataType: "integer"},
+				"name": {Name: "name", Description: "The customer's name"},
+			}},
+			"model.test.orders": {Name: "orders", Columns: map[string]NodeColumn{
+				"id":          {Name: "id", Description: "The order id"},
+				"customer_id": {Name: "customer_id", Description: "Who placed the order"},
+			}},
+		},
+		Sources: map[string]Source{
+			"source.test.raw.payments": {Name: "payments", SourceName: "raw", Columns: map[string]NodeColumn{
+				"amount": {Name: "amount", Description: "Amount in cents"},
+			}},
+		},
+	}
+
+	content := `select c.id, o.id, name, p.amount
+from {{ ref('customers') }} as c
+join {{ ref('orders') }} o on o.customer_id = c.id
+join {{ source('raw', 'payments') }} p using (id)`
+
+	testColumnWrapper(manifest, content, "c.id", 2, "model.test.customers", "The primary key for this table", t)
+	testColumnWrapper(manifest, content, "o.id", 2, "model.test.orders", "The order id", t)
+	testColumnWrapper(manifest, content, "name", 0, "model.test.customers", "The customer's name", t)
+	testColumnWrapper(manifest, content, "p.amount", 2, "source.test.raw.payments", "Amount in cents", t)
+
+	if _, ok := getColumnAtPosition(manifest, "model.test.report", content, strings.Index(content, "c.id")); ok {
+		t.Errorf("the alias itself should not resolve to a column")
+	}
+
+	column, _ := getColumnAtPosition(manifest, "model.test.report", content, strings.Index(content, "c.id")+2)
+	if !strings.Contains(column.GetHoverText(), "`integer`") {
+		t.Errorf("expected the data type in %v", column.GetHoverText())
+	}
+}
+
+func testColumnWrapper(manifest Manifest, content, search string, offset int, expectedOwner, expectedDescription string, t *testing.T) {
+	column, ok := getColumnAtPosition(manifest, "model.test.report", content, strings.Index(content, search)+offset)
+	if !ok {
+		t.Errorf("could not resolve %v", search)
+		return
+	}
+
+	if column.OwnerKey != expectedOwner || column.Column.Description != expectedDescription {
+		t.Errorf("got wrong column for %v: %+v", search, column)
+	}
+}
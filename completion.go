@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/joro550/dbt-language-server/jinja"
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+type CompletionContext struct {
+	// Function is the jinja function whose string argument the cursor is in, e.g. ref or source
+	Function string
+	// Arguments holds the string arguments that come before the one being completed
+	Arguments []string
+	Prefix    string
+}
+
+func (w *Workspace) completionHandler(context *glsp.Context, params *protocol.CompletionParams) (any, error) {
+	completionLog := commonlog.GetLoggerf("%s.completion", lsName)
+	manifest := w.Manifest()
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		completionLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	fileString := string(fileContent)
+	rawPosition := getRawPositionInFile(fileString, params.Position.Line, params.Position.Character)
+
+	if configContext, ok := getConfigCompletionContext(fileString, rawPosition); ok {
+		completionLog.Infof("completing config %v with prefix %v", configContext.Key, configContext.Prefix)
+		return getConfigCompletions(configContext), nil
+	}
+
+	completionContext, ok := getCompletionContext(fileString, rawPosition)
+	if !ok {
+		if prefix, ok := getMacroCompletionPrefix(fileString, rawPosition); ok {
+			return getMacroCompletions(manifest, prefix), nil
+		}
+		return nil, nil
+	}
+
+	completionLog.Infof("completing %v argument %v with prefix %v", completionContext.Function, len(completionContext.Arguments), completionContext.Prefix)
+	switch completionContext.Function {
+	case "ref":
+		return getRefCompletions(manifest, completionContext), nil
+	case "source":
+		return getSourceCompletions(manifest, completionContext), nil
+	case "var":
+		return getVarCompletions(manifest, completionContext), nil
+	}
+	return nil, nil
+}
+
+// getCompletionContext lexes the jinja expression the cursor is in and works out
+// whether we are inside the quotes of a function argument
+func getCompletionContext(content string, rawPosition int) (CompletionContext, bool) {
+	tokens, ok := getJinjaTokensBeforeCursor(content, rawPosition)
+	if !ok {
+		return CompletionContext{}, false
+	}
+
+	functionIndex := -1
+	for i := len(tokens) - 1; i > 0; i-- {
+		if tokens[i].Token == jinja.LEFT_BRACKET && tokens[i-1].Token == jinja.IDENT {
+			functionIndex = i - 1
+			break
+		}
+	}
+	if functionIndex == -1 {
+		return CompletionContext{}, false
+	}
+
+	arguments := []string{}
+	argumentTokens := tokens[functionIndex+2:]
+	for i, tok := range argumentTokens {
+		switch tok.Token {
+		case jinja.STRING:
+			// an unterminated string can only be the one the cursor is in
+			if len(tok.Value) < 2 || tok.Value[len(tok.Value)-1] != tok.Value[0] {
+				if i != len(argumentTokens)-1 {
+					return CompletionContext{}, false
+				}
+
+				return CompletionContext{
+					Function:  tokens[functionIndex].Value,
+					Arguments: arguments,
+					Prefix:    tok.Value[1:],
+				}, true
+			}
+			arguments = append(arguments, tok.Value[1:len(tok.Value)-1])
+		case jinja.COMMA:
+		default:
+			return CompletionContext{}, false
+		}
+	}
+
+	return CompletionContext{}, false
+}
+
+func getRefCompletions(manifest Manifest, refContext CompletionContext) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	if len(refContext.Arguments) > 1 {
+		return items
+	}
+
+	packages := []string{}
+	modelKind := protocol.CompletionItemKindFile
+	packageKind := protocol.CompletionItemKindModule
+
+	for key, node := range manifest.Nodes {
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) != 3 || parts[0] != "model" {
+			continue
+		}
+
+		packageName, modelName := parts[1], parts[2]
+		if len(refContext.Arguments) == 0 && !slices.Contains(packages, packageName) && strings.HasPrefix(packageName, refContext.Prefix) {
+			packages = append(packages, packageName)
+		}
+
+		if len(refContext.Arguments) == 1 && packageName != refContext.Arguments[0] {
+			continue
+		}
+
+		if !strings.HasPrefix(modelName, refContext.Prefix) {
+			continue
+		}
+
+		detail := key
+		items = append(items, protocol.CompletionItem{
+			Label:  modelName,
+			Kind:   &modelKind,
+			Detail: &detail,
+			Documentation: protocol.MarkupContent{
+				Kind:  protocol.MarkupKindMarkdown,
+				Value: node.Description,
+			},
+		})
+	}
+
+	for _, packageName := range packages {
+		detail := fmt.Sprintf("package %v", packageName)
+		items = append(items, protocol.CompletionItem{
+			Label:  packageName,
+			Kind:   &packageKind,
+			Detail: &detail,
+		})
+	}
+
+	slices.SortFunc(items, func(a, b protocol.CompletionItem) int {
+		return strings.Compare(a.Label, b.Label)
+	})
+	return items
+}
+
+func getSourceCompletions(manifest Manifest, sourceContext CompletionContext) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	sourceNames := []string{}
+	sourceKind := protocol.CompletionItemKindModule
+	tableKind := protocol.CompletionItemKindStruct
+
+	for key, source := range manifest.Sources {
+		switch len(sourceContext.Arguments) {
+		case 0:
+			if slices.Contains(sourceNames, source.SourceName) || !strings.HasPrefix(source.SourceName, sourceContext.Prefix) {
+				continue
+			}
+
+			sourceNames = append(sourceNames, source.SourceName)
+			detail := fmt.Sprintf("source %v", source.SourceName)
+			items = append(items, protocol.CompletionItem{
+				Label:  source.SourceName,
+				Kind:   &sourceKind,
+				Detail: &detail,
+				Documentation: protocol.MarkupContent{
+					Kind:  protocol.MarkupKindMarkdown,
+					Value: source.SourceDescription,
+				},
+			})
+		case 1:
+			if source.SourceName != sourceContext.Arguments[0] || !strings.HasPrefix(source.Name, sourceContext.Prefix) {
+				continue
+			}
+
+			detail := key
+			items = append(items, protocol.CompletionItem{
+				Label:  source.Name,
+				Kind:   &tableKind,
+				Detail: &detail,
+				Documentation: protocol.MarkupContent{
+					Kind:  protocol.MarkupKindMarkdown,
+					Value: source.GetHoverText(),
+				},
+			})
+		}
+	}
+
+	slices.SortFunc(items, func(a, b protocol.CompletionItem) int {
+		return strings.Compare(a.Label, b.Label)
+	})
+	return items
+}
+
+// getVarCompletions offers the global vars and the ones scoped to the project, a
+// scoped var hides the global one with the same name
+func getVarCompletions(manifest Manifest, varContext CompletionContext) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	if len(varContext.Arguments) > 0 {
+		return items
+	}
+
+	kind := protocol.CompletionItemKindVariable
+	for _, projectVar := range manifest.Vars {
+		if projectVar.Package != "" && projectVar.Package != manifest.Metadata.ProjectName {
+			continue
+		}
+		if !strings.HasPrefix(projectVar.Name, varContext.Prefix) {
+			continue
+		}
+		if found, _ := manifest.FindVar(manifest.Metadata.ProjectName, projectVar.Name); found.Package != projectVar.Package {
+			continue
+		}
+
+		items = append(items, protocol.CompletionItem{
+			Label:  projectVar.Name,
+			Kind:   &kind,
+			Detail: stringPointer(projectVar.ValueString()),
+		})
+	}
+
+	slices.SortFunc(items, func(a, b protocol.CompletionItem) int {
+		return strings.Compare(a.Label, b.Label)
+	})
+	return items
+}
+
+// getJinjaTokensBeforeCursor lexes from the start of the {{ }} or {% %} tag the
+// cursor is in up to the cursor
+func getJinjaTokensBeforeCursor(content string, rawPosition int) ([]jinja.Token, bool) {
+	if rawPosition > len(content) {
+		rawPosition = len(content)
+	}
+
+	beforeCursor := content[:rawPosition]
+	start := max(strings.LastIndex(beforeCursor, "{{"), strings.LastIndex(beforeCursor, "{%"))
+	if start == -1 || strings.Contains(beforeCursor[start:], "}}") || strings.Contains(beforeCursor[start:], "%}") {
+		return nil, false
+	}
+
+	tokens := []jinja.Token{}
+	lexer := jinja.NewJinjaLexer(beforeCursor[start:])
+	for tok := lexer.NextToken(); tok.Token != jinja.EOF; tok = lexer.NextToken() {
+		// make the offsets relative to the whole file
+		tok.Start += start
+		tok.End += start
+		tokens = append(tokens, tok)
+	}
+	return tokens, true
+}
+
+// getMacroCompletionPrefix returns the partial name being typed when the cursor is
+// somewhere a macro call could start, namespaced names come back as package.name
+func getMacroCompletionPrefix(content string, rawPosition int) (string, bool) {
+	tokens, ok := getJinjaTokensBeforeCursor(content, rawPosition)
+	if !ok || len(tokens) == 0 {
+		return "", false
+	}
+	rawPosition = min(rawPosition, len(content))
+
+	// the header of a macro or test definition only holds its name and parameters
+	if len(tokens) > 1 && tokens[0].Token == jinja.START_STATEMENT && (tokens[1].Token == jinja.MACRO || tokens[1].Value == "test" || tokens[1].Value == "materialization") {
+		return "", false
+	}
+
+	last := tokens[len(tokens)-1]
+	if last.Token == jinja.STRING && (len(last.Value) < 2 || last.Value[len(last.Value)-1] != last.Value[0]) {
+		return "", false
+	}
+
+	if last.End != rawPosition {
+		return "", true
+	}
+
+	// a name can be namespaced by one package, anything deeper is an attribute
+	isPackage := func(index int) bool {
+		return index >= 0 && tokens[index].Token == jinja.IDENT && (index == 0 || tokens[index-1].Token != jinja.DOT)
+	}
+
+	switch last.Token {
+	case jinja.IDENT:
+		if len(tokens) < 2 || tokens[len(tokens)-2].Token != jinja.DOT {
+			return last.Value, true
+		}
+		if isPackage(len(tokens) - 3) {
+			return tokens[len(tokens)-3].Value + "." + last.Value, true
+		}
+	case jinja.DOT:
+		if isPackage(len(tokens) - 2) {
+			return tokens[len(tokens)-2].Value + ".", true
+		}
+	case jinja.START_EXPRESSION, jinja.START_STATEMENT, jinja.LEFT_BRACKET, jinja.COMMA:
+		return "", true
+	}
+	return "", false
+}
+
+// getMacroCompletions completes macros of the root project, or of a package when
+// the prefix is namespaced. Installed packages are offered as namespaces
+func getMacroCompletions(manifest Manifest, prefix string) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	packages := []string{}
+	macroKind := protocol.CompletionItemKindFunction
+	packageKind := protocol.CompletionItemKindModule
+	snippetFormat := protocol.InsertTextFormatSnippet
+
+	packageName, namePrefix, namespaced := strings.Cut(prefix, ".")
+	if !namespaced {
+		packageName, namePrefix = manifest.Metadata.ProjectName, prefix
+	}
+
+	for key, macro := range manifest.Macros {
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if !namespaced && parts[1] != packageName && !slices.Contains(packages, parts[1]) && strings.HasPrefix(parts[1], prefix) {
+			packages = append(packages, parts[1])
+		}
+
+		if parts[1] != packageName || !strings.HasPrefix(macro.Name, namePrefix) {
+			continue
+		}
+
+		documentation := fmt.Sprintf("_%s_", key)
+		if macro.Description != "" {
+			documentation = macro.Description + "\n\n" + documentation
+		}
+
+		detail := macro.Signature()
+		insertText := macro.Snippet()
+		items = append(items, protocol.CompletionItem{
+			Label:            macro.Name,
+			Kind:             &macroKind,
+			Detail:           &detail,
+			InsertText:       &insertText,
+			InsertTextFormat: &snippetFormat,
+			Documentation: protocol.MarkupContent{
+				Kind:  protocol.MarkupKindMarkdown,
+				Value: documentation,
+			},
+		})
+	}
+
+	for _, packageName := range packages {
+		detail := fmt.Sprintf("package %v", packageName)
+		items = append(items, protocol.CompletionItem{
+			Label:  packageName,
+			Kind:   &packageKind,
+			Detail: &detail,
+		})
+	}
+
+	slices.SortFunc(items, func(a, b protocol.CompletionItem) int {
+		return strings.Compare(a.Label, b.Label)
+	})
+	return items
+}
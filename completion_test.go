@@ -0,0 +1,162 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompletionContext(t *testing.T) {
+	testCompletionContextWrapper(`{{ ref('`, true, "ref", []string{}, "", t)
+	testCompletionContextWrapper(`{{ ref('my_fi`, true, "ref", []string{}, "my_fi", t)
+	testCompletionContextWrapper(`select * from {{ ref("model_2`, true, "ref", []string{}, "model_2", t)
+	testCompletionContextWrapper(`{{ ref('project', 'my_`, true, "ref", []string{"project"}, "my_", t)
+	testCompletionContextWrapper(`{{ source('raw', 'ord`, true, "source", []string{"raw"}, "ord", t)
+	testCompletionContextWrapper(`{{ ref('my_first_dbt_model') }} `, false, "", nil, "", t)
+	testCompletionContextWrapper(`{{ ref('my_first_dbt_model') `, false, "", nil, "", t)
+	testCompletionContextWrapper(`{{ ref(`, false, "", nil, "", t)
+}
+
+func TestRefCompletions(t *testing.T) {
+	manifest := Manifest{Nodes: map[string]Node{
+		"model.test.my_first_dbt_model":  {Name: "my_first_dbt_model", Description: "A starter dbt model"},
+		"model.test.my_second_dbt_model": {Name: "my_second_dbt_model"},
+		"model.other.my_other_model":     {Name: "my_other_model"},
+	}}
+
+	items := getRefCompletions(manifest, CompletionContext{Function: "ref", Prefix: "my_f"})
+	if len(items) != 1 || items[0].Label != "my_first_dbt_model" {
+		t.Errorf("expected my_first_dbt_model but got %v", items)
+	}
+
+	items = getRefCompletions(manifest, CompletionContext{Function: "ref", Prefix: "ot"})
+	if len(items) != 1 || items[0].Label != "other" {
+		t.Errorf("expected package other but got %v", items)
+	}
+
+	items = getRefCompletions(manifest, CompletionContext{Function: "ref", Arguments: []string{"other"}})
+	if len(items) != 1 || items[0].Label != "my_other_model" {
+		t.Errorf("expected my_other_model but got %v", items)
+	}
+}
+
+func TestSourceCompletions(t *testing.T) {
+	settings := ProjectSettings{
+		Name:         "test",
+		RootPath:     "./tests",
+		PathSettings: pathSettings{ModelPath: []string{"."}},
+	}
+
+	sources, err := settings.GetSources()
+	if err != nil {
+		t.Fatalf("error %v", err)
+	}
+	manifest := Manifest{Sources: sources}
+
+	items := getSourceCompletions(manifest, CompletionContext{Function: "source"})
+	if len(items) != 1 || items[0].Label != "raw" {
+		t.Errorf("expected source raw but got %v", items)
+	}
+
+	items = getSourceCompletions(manifest, CompletionContext{Function: "source", Arguments: []string{"raw"}})
+	if len(items) != 2 || items[0].Label != "customers" || items[1].Label != "orders" {
+		t.Errorf("expected the raw tables but got %v", items)
+	}
+}
+
+func testCompletionContextWrapper(content string, expectedOk bool, expectedFunction string, expectedArguments []string, expectedPrefix string, t *testing.T) {
+	completionContext, ok := getCompletionContext(content, len(content))
+	if ok != expectedOk {
+		t.Errorf("expected %v for %v but got %v", expectedOk, content, ok)
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	if completionContext.Function != expectedFunction || completionContext.Prefix != expectedPrefix || !slices.Equal(completionContext.Arguments, expectedArguments) {
+		t.Errorf("got wrong context for %v: %+v", content, completionContext)
+	}
+}
+
+func TestMacroCompletions(t *testing.T) {
+	prefixes := []struct {
+		content string
+		ok      bool
+		prefix  string
+	}{
+		{`{{ cen`, true, "cen"},
+		{`{{ `, true, ""},
+		{`{% set x = cen`, true, "cen"},
+		{`{{ dbt_utils.st`, true, "dbt_utils.st"},
+		{`{{ dbt_utils.`, true, "dbt_utils."},
+		{`{{ a.b.c`, false, ""},
+		{`{{ ref('cen`, false, ""},
+		{`{{ cen }} cen`, false, ""},
+		{`{% macro cents_to_dollars(col`, false, ""},
+		{`{% macro cents_to_dollars(column, `, false, ""},
+		{`{%- test not_negative(model, `, false, ""},
+	}
+
+	for _, tt := range prefixes {
+		prefix, ok := getMacroCompletionPrefix(tt.content, len(tt.content))
+		if ok != tt.ok || prefix != tt.prefix {
+			t.Errorf("%v: expected %v %v but got %v %v", tt.content, tt.ok, tt.prefix, ok, prefix)
+		}
+	}
+
+	manifest := Manifest{Metadata: Metadata{ProjectName: "test"}, Macros: map[string]Macro{
+		"macro.test.cents_to_dollars": {Name: "cents_to_dollars", Arguments: []MacroArgument{{Name: "column"}, {Name: "scale", Default: "2"}}},
+		"macro.test.hello":            {Name: "hello"},
+		"macro.dbt_utils.star":        {Name: "star", Arguments: []MacroArgument{{Name: "from"}}},
+		"macro.dbt_utils.surrogate":   {Name: "surrogate"},
+	}}
+
+	items := getMacroCompletions(manifest, "cen")
+	if len(items) != 1 || items[0].Label != "cents_to_dollars" {
+		t.Fatalf("expected cents_to_dollars but got %v", items)
+	}
+
+	if *items[0].InsertText != "cents_to_dollars(${1:column}, ${2:scale=2})$0" || *items[0].Detail != "cents_to_dollars(column, scale=2)" {
+		t.Errorf("got wrong snippet %v", *items[0].InsertText)
+	}
+}
+
+func TestPackageMacroCompletions(t *testing.T) {
+	manifest := Manifest{Metadata: Metadata{ProjectName: "test"}, Macros: map[string]Macro{
+		"macro.test.hello":            {Name: "hello"},
+		"macro.dbt_utils.star":        {Name: "star", Arguments: []MacroArgument{{Name: "from"}}},
+		"macro.dbt_utils.surrogate":   {Name: "surrogate"},
+		"macro.dbt_date.get_date_dim": {Name: "get_date_dim"},
+	}}
+
+	items := getMacroCompletions(manifest, "dbt")
+	if len(items) != 2 || items[0].Label != "dbt_date" || items[1].Label != "dbt_utils" {
+		t.Errorf("expected the packages but got %v", items)
+	}
+
+	items = getMacroCompletions(manifest, "dbt_utils.s")
+	if len(items) != 2 || items[0].Label != "star" || *items[0].InsertText != "star(${1:from})$0" {
+		t.Errorf("expected dbt_utils macros but got %v", items)
+	}
+
+	if items := getMacroCompletions(manifest, "sta"); len(items) != 0 {
+		t.Errorf("package macros need their namespace, got %v", items)
+	}
+}
+
+func TestVarCompletions(t *testing.T) {
+	testCompletionContextWrapper(`{{ var('sta`, true, "var", []string{}, "sta", t)
+
+	manifest := Manifest{Metadata: Metadata{ProjectName: "test"}, Vars: []ProjectVar{
+		{Name: "start_date", Value: "2020-01-01"},
+		{Name: "limit", Value: 10},
+		{Name: "limit", Package: "test", Value: 20},
+		{Name: "surrogate_key_treat_nulls_as_empty_strings", Package: "dbt_utils", Value: true},
+	}}
+
+	items := getVarCompletions(manifest, CompletionContext{Function: "var"})
+	if len(items) != 2 || items[0].Label != "limit" || *items[0].Detail != "20" || items[1].Label != "start_date" {
+		t.Errorf("expected the project vars but got %v", items)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/joro550/dbt-language-server/jinja"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// configKey describes a key config() accepts
+type configKey struct {
+	Name        string
+	Description string
+	// Type is one of string, boolean, strings (a string or a list of them), dict,
+	// empty when anything goes
+	Type string
+	// Values are the accepted values of keys that take one of a fixed set of strings
+	Values []string
+	// Extensible keys also accept values we can't know about, like custom
+	// materializations or adapter specific strategies
+	Extensible bool
+}
+
+// ConfigCompletionContext is the config() argument the cursor is in, Key is empty
+// while the key itself is being typed
+type ConfigCompletionContext struct {
+	Key      string
+	Prefix   string
+	InString bool
+}
+
+var configKeys = []configKey{
+	{Name: "materialized", Description: "How the model is built in the warehouse", Type: "string", Values: []string{"view", "table", "incremental", "ephemeral", "materialized_view"}, Extensible: true},
+	{Name: "enabled", Description: "Whether the resource is part of the project", Type: "boolean"},
+	{Name: "tags", Description: "Tags used to select the resource", Type: "strings"},
+	{Name: "schema", Description: "The custom schema the model is built in", Type: "string"},
+	{Name: "database", Description: "The database the model is built in", Type: "string"},
+	{Name: "alias", Description: "The name of the relation in the warehouse", Type: "string"},
+	{Name: "unique_key", Description: "The column, or columns, identifying a row of an incremental model", Type: "strings"},
+	{Name: "incremental_strategy", Description: "How an incremental model applies new rows", Type: "string", Values: []string{"append", "merge", "delete+insert", "insert_overwrite", "microbatch"}, Extensible: true},
+	{Name: "on_schema_change", Description: "What an incremental model does when its columns change", Type: "string", Values: []string{"ignore", "fail", "append_new_columns", "sync_all_columns"}},
+	{Name: "incremental_predicates", Description: "Extra conditions for the incremental merge", Type: "strings"},
+	{Name: "merge_update_columns", Description: "The columns a merge updates", Type: "strings"},
+	{Name: "merge_exclude_columns", Description: "The columns a merge leaves alone", Type: "strings"},
+	{Name: "full_refresh", Description: "Whether --full-refresh rebuilds the model", Type: "boolean"},
+	{Name: "event_time", Description: "The column holding when a row happened", Type: "string"},
+	{Name: "batch_size", Description: "The time span of a microbatch", Type: "string", Values: []string{"hour", "day", "month", "year"}},
+	{Name: "lookback", Description: "How many batches before the latest are reprocessed"},
+	{Name: "begin", Description: "When the first microbatch starts", Type: "string"},
+	{Name: "pre_hook", Description: "SQL run before the model is built", Type: "strings"},
+	{Name: "post_hook", Description: "SQL run after the model is built", Type: "strings"},
+	{Name: "persist_docs", Description: "Whether descriptions are stored as comments in the warehouse", Type: "dict"},
+	{Name: "meta", Description: "Any metadata for the resource", Type: "dict"},
+	{Name: "docs", Description: "How the resource shows up in the docs site", Type: "dict"},
+	{Name: "grants", Description: "The privileges granted on the relation", Type: "dict"},
+	{Name: "contract", Description: "Whether the model's columns and types are enforced", Type: "dict"},
+	{Name: "column_types", Description: "The column types of a seed", Type: "dict"},
+	{Name: "quoting", Description: "Whether database, schema and identifier are quoted", Type: "dict"},
+	{Name: "access", Description: "Who can ref the model", Type: "string", Values: []string{"private", "protected", "public"}},
+	{Name: "group", Description: "The group the model belongs to", Type: "string"},
+	{Name: "sql_header", Description: "SQL run before the create statement", Type: "string"},
+	{Name: "packages", Description: "The packages a python model needs", Type: "strings"},
+	{Name: "strategy", Description: "How a snapshot detects changes", Type: "string", Values: []string{"timestamp", "check"}},
+	{Name: "updated_at", Description: "The column a timestamp snapshot compares", Type: "string"},
+	{Name: "check_cols", Description: "The columns a check snapshot compares", Type: "strings"},
+	{Name: "target_schema", Description: "The schema a snapshot is built in", Type: "string"},
+	{Name: "target_database", Description: "The database a snapshot is built in", Type: "string"},
+	{Name: "invalidate_hard_deletes", Description: "Whether deleted rows get a dbt_valid_to", Type: "boolean"},
+	{Name: "hard_deletes", Description: "How a snapshot treats deleted rows", Type: "string", Values: []string{"ignore", "invalidate", "new_record"}},
+	{Name: "dbt_valid_to_current", Description: "The dbt_valid_to of current snapshot rows", Type: "string"},
+	{Name: "snapshot_meta_column_names", Description: "Renames the dbt_ columns of a snapshot", Type: "dict"},
+	// adapter specific configs
+	{Name: "partition_by", Description: "How the table is partitioned"},
+	{Name: "cluster_by", Description: "The columns the table is clustered by", Type: "strings"},
+	{Name: "transient", Description: "Whether a snowflake table is transient", Type: "boolean"},
+	{Name: "copy_grants", Description: "Whether snowflake keeps grants when the table is replaced", Type: "boolean"},
+	{Name: "secure", Description: "Whether a snowflake view is secure", Type: "boolean"},
+	{Name: "query_tag", Description: "The snowflake query tag", Type: "string"},
+	{Name: "snowflake_warehouse", Description: "The warehouse the model is built with", Type: "string"},
+	{Name: "target_lag", Description: "How stale a dynamic table may get", Type: "string"},
+	{Name: "labels", Description: "The bigquery labels of the relation", Type: "dict"},
+	{Name: "require_partition_filter", Description: "Whether bigquery queries must filter on the partition", Type: "boolean"},
+	{Name: "partition_expiration_days", Description: "How long bigquery keeps a partition"},
+	{Name: "hours_to_expiration", Description: "How long bigquery keeps the table"},
+	{Name: "kms_key_name", Description: "The bigquery encryption key", Type: "string"},
+	{Name: "dist", Description: "The redshift distribution style or key", Type: "string"},
+	{Name: "sort", Description: "The redshift sort key", Type: "strings"},
+	{Name: "sort_type", Description: "The redshift sort key type", Type: "string", Values: []string{"compound", "interleaved"}},
+	{Name: "bind", Description: "Whether a redshift view is bound to its tables", Type: "boolean"},
+	{Name: "indexes", Description: "The postgres indexes of the table"},
+	{Name: "unlogged", Description: "Whether a postgres table is unlogged", Type: "boolean"},
+	{Name: "file_format", Description: "The file format of a spark or databricks table", Type: "string"},
+	{Name: "location_root", Description: "Where a spark or databricks table is stored", Type: "string"},
+	{Name: "tblproperties", Description: "The properties of a spark or databricks table", Type: "dict"},
+}
+
+// configKeyAliases are the spellings dbt accepts for a key besides its own, the
+// hooks are written with a hyphen in yaml
+var configKeyAliases = map[string]string{
+	"pre-hook":  "pre_hook",
+	"post-hook": "post_hook",
+}
+
+func findConfigKey(name string) (configKey, bool) {
+	if alias, ok := configKeyAliases[name]; ok {
+		name = alias
+	}
+
+	for _, key := range configKeys {
+		if key.Name == name {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+// findMisspelledConfigKey returns the known key closest to name when name looks
+// like a typo of it. Other keys are left alone, adapters and packages add their own
+func findMisspelledConfigKey(name string) (configKey, bool) {
+	best, bestDistance := configKey{}, 0
+	for _, key := range configKeys {
+		distance := editDistance(name, key.Name)
+		if distance == 0 || distance > 2 || distance*3 > len(key.Name) {
+			continue
+		}
+		if best.Name == "" || distance < bestDistance {
+			best, bestDistance = key, distance
+		}
+	}
+	return best, best.Name != ""
+}
+
+// getConfigCompletionContext works out whether the cursor is on a key or a value of
+// a config() call
+func getConfigCompletionContext(content string, rawPosition int) (ConfigCompletionContext, bool) {
+	callContext, ok := getCallContext(content, rawPosition)
+	if !ok || callContext.Function != "config" {
+		return ConfigCompletionContext{}, false
+	}
+
+	tokens, ok := getJinjaTokensBeforeCursor(content, rawPosition)
+	if !ok || len(tokens) == 0 {
+		return ConfigCompletionContext{}, false
+	}
+	rawPosition = min(rawPosition, len(content))
+
+	last := tokens[len(tokens)-1]
+	var previous jinja.Token
+	if len(tokens) > 1 {
+		previous = tokens[len(tokens)-2]
+	}
+
+	if callContext.Keyword != "" {
+		switch {
+		case last.Token == jinja.STRING && (len(last.Value) < 2 || last.Value[len(last.Value)-1] != last.Value[0]):
+			if previous.Token != jinja.ASSIGN {
+				return ConfigCompletionContext{}, false
+			}
+			return ConfigCompletionContext{Key: callContext.Keyword, Prefix: last.Value[1:], InString: true}, true
+		case last.Token == jinja.ASSIGN:
+			return ConfigCompletionContext{Key: callContext.Keyword}, true
+		case last.End == rawPosition && previous.Token == jinja.ASSIGN && isNameToken(last):
+			return ConfigCompletionContext{Key: callContext.Keyword, Prefix: last.Value}, true
+		}
+		return ConfigCompletionContext{}, false
+	}
+
+	switch {
+	case last.Token == jinja.LEFT_BRACKET || last.Token == jinja.COMMA:
+		return ConfigCompletionContext{}, true
+	case last.Token == jinja.IDENT && last.End == rawPosition && (previous.Token == jinja.LEFT_BRACKET || previous.Token == jinja.COMMA):
+		return ConfigCompletionContext{Prefix: last.Value}, true
+	}
+	return ConfigCompletionContext{}, false
+}
+
+// isNameToken is true for identifiers and the keywords a bare value can start as
+func isNameToken(tok jinja.Token) bool {
+	return tok.Token == jinja.IDENT || tok.Token == jinja.TRUE || tok.Token == jinja.FALSE || tok.Token == jinja.NONE
+}
+
+func getConfigCompletions(configContext ConfigCompletionContext) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	snippet := protocol.InsertTextFormatSnippet
+
+	if configContext.Key == "" {
+		kind := protocol.CompletionItemKindProperty
+		for _, key := range configKeys {
+			if !strings.HasPrefix(key.Name, configContext.Prefix) {
+				continue
+			}
+
+			insertText := key.Name + "=$0"
+			switch {
+			case len(key.Values) > 0:
+				insertText = fmt.Sprintf("%s='${1|%s|}'$0", key.Name, strings.Join(key.Values, ","))
+			case key.Type == "boolean":
+				insertText = key.Name + "=${1|true,false|}$0"
+			case key.Type == "string":
+				insertText = key.Name + "='$1'$0"
+			case key.Type == "dict":
+				insertText = key.Name + "={$1}$0"
+			}
+
+			items = append(items, protocol.CompletionItem{
+				Label:            key.Name,
+				Kind:             &kind,
+				Detail:           stringPointer(key.Type),
+				Documentation:    key.Description,
+				InsertText:       &insertText,
+				InsertTextFormat: &snippet,
+			})
+		}
+		return items
+	}
+
+	key, ok := findConfigKey(configContext.Key)
+	if !ok {
+		return items
+	}
+
+	kind := protocol.CompletionItemKindEnumMember
+	values := key.Values
+	if key.Type == "boolean" && !configContext.InString {
+		values = []string{"true", "false"}
+	}
+
+	for _, value := range values {
+		if !strings.HasPrefix(value, configContext.Prefix) {
+			continue
+		}
+
+		item := protocol.CompletionItem{Label: value, Kind: &kind}
+		if !configContext.InString && key.Type != "boolean" {
+			insertText := fmt.Sprintf("'%s'", value)
+			item.InsertText = &insertText
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// getConfigDiagnostics warns about config() keys that look like typos of known
+// ones and reports literal values of the wrong type, or outside of the accepted
+// values, as errors. Other unknown keys are only information, adapters and
+// packages add their own
+func getConfigDiagnostics(parser *JinjaParser, content string) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	source := lsName
+
+	add := func(r Range, severity protocol.DiagnosticSeverity, message string) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    getRangeInFile(content, r),
+			Severity: &severity,
+			Source:   &source,
+			Message:  message,
+		})
+	}
+
+	for _, block := range parser.GetConfigBlocks(content) {
+		for _, argument := range block.Arguments {
+			key, ok := findConfigKey(argument.Key)
+			if !ok {
+				if known, misspelled := findMisspelledConfigKey(argument.Key); misspelled {
+					add(argument.KeyRange, protocol.DiagnosticSeverityWarning, fmt.Sprintf("unknown config '%s', did you mean '%s'?", argument.Key, known.Name))
+				} else {
+					add(argument.KeyRange, protocol.DiagnosticSeverityInformation, fmt.Sprintf("unknown config '%s'", argument.Key))
+				}
+				continue
+			}
+
+			if !argument.IsLiteral || argument.Value == nil {
+				continue
+			}
+
+			if !isConfigType(argument.Value, key.Type) {
+				add(argument.ValueRange, protocol.DiagnosticSeverityError, fmt.Sprintf("config '%s' expects a %s value", key.Name, getConfigTypeName(key.Type)))
+				continue
+			}
+
+			value, isString := argument.Value.(string)
+			if !isString || len(key.Values) == 0 || slices.Contains(key.Values, value) {
+				continue
+			}
+
+			severity := protocol.DiagnosticSeverityError
+			if key.Extensible {
+				severity = protocol.DiagnosticSeverityWarning
+			}
+			add(argument.ValueRange, severity, fmt.Sprintf("'%s' is not a valid %s, expected one of %s", value, key.Name, strings.Join(key.Values, ", ")))
+		}
+	}
+
+	return diagnostics
+}
+
+func isConfigType(value any, configType string) bool {
+	switch configType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "dict":
+		_, ok := value.(map[string]any)
+		return ok
+	case "strings":
+		switch list := value.(type) {
+		case string:
+			return true
+		case []any:
+			for _, item := range list {
+				if _, ok := item.(string); !ok {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func getConfigTypeName(configType string) string {
+	switch configType {
+	case "boolean":
+		return "true or false"
+	case "strings":
+		return "string or list of strings"
+	}
+	return configType
+}
+
+// mergeConfigBlocks applies the config() calls of a model on top of the config
+// from dbt_project.yml, values we can't evaluate are kept as written
+func mergeConfigBlocks(config map[string]any, blocks []ConfigReference) map[string]any {
+	merged := map[string]any{}
+	for key, value := range config {
+		merged[key] = value
+	}
+
+	for _, block := range blocks {
+		for _, argument := range block.Arguments {
+			var value any = argument.Expression
+			if argument.IsLiteral {
+				value = argument.Value
+			}
+
+			if argument.Key == "tags" {
+				merged["tags"] = slices.Concat(toStringList(merged["tags"]), toStringList(value))
+				continue
+			}
+			merged[argument.Key] = value
+		}
+	}
+
+	return merged
+}
+
+// getConfigHoverText shows the description and effective value of key, or the
+// whole effective config when key is empty
+func getConfigHoverText(config map[string]any, key string) string {
+	if key != "" {
+		builder := strings.Builder{}
+		builder.WriteString(fmt.Sprintf("**%s**", key))
+		if definition, ok := findConfigKey(key); ok {
+			builder.WriteString(fmt.Sprintf("\n\n%s", definition.Description))
+		}
+		if value, ok := config[key]; ok {
+			builder.WriteString(fmt.Sprintf("\n\neffective value:\n```yaml\n%s\n```", configYaml(value)))
+		}
+		return builder.String()
+	}
+
+	if len(config) == 0 {
+		return "**config**\n\nno config set"
+	}
+	return fmt.Sprintf("**config**\n\n```yaml\n%s\n```", configYaml(config))
+}
+
+func configYaml(value any) string {
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return strings.TrimSpace(string(out))
+}
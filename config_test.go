@@ -0,0 +1,123 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestConfigBlocks(t *testing.T) {
+	content := `{{ config(materialized='incremental', tags=['daily', 'finance'], enabled=var('enabled'), meta={'owner': 'data'}) }}`
+
+	blocks := NewJinjaParser().GetConfigBlocks(content)
+	if len(blocks) != 1 || len(blocks[0].Arguments) != 4 {
+		t.Fatalf("expected 1 config with 4 arguments but got %+v", blocks)
+	}
+
+	arguments := blocks[0].Arguments
+	if arguments[0].Key != "materialized" || arguments[0].Value != "incremental" || content[arguments[0].KeyRange.Start:arguments[0].KeyRange.End] != "materialized" {
+		t.Errorf("got wrong argument %+v", arguments[0])
+	}
+
+	if tags, ok := arguments[1].Value.([]any); !ok || len(tags) != 2 {
+		t.Errorf("expected the tags list but got %+v", arguments[1])
+	}
+
+	if arguments[2].IsLiteral {
+		t.Errorf("var() can't be evaluated, got %+v", arguments[2])
+	}
+
+	if meta, ok := arguments[3].Value.(map[string]any); !ok || meta["owner"] != "data" {
+		t.Errorf("expected the meta dict but got %+v", arguments[3])
+	}
+}
+
+func TestConfigCompletions(t *testing.T) {
+	contexts := []struct {
+		content  string
+		ok       bool
+		expected ConfigCompletionContext
+	}{
+		{`{{ config(`, true, ConfigCompletionContext{}},
+		{`{{ config(mat`, true, ConfigCompletionContext{Prefix: "mat"}},
+		{`{{ config(materialized='table', uni`, true, ConfigCompletionContext{Prefix: "uni"}},
+		{`{{ config(materialized=`, true, ConfigCompletionContext{Key: "materialized"}},
+		{`{{ config(materialized='incr`, true, ConfigCompletionContext{Key: "materialized", Prefix: "incr", InString: true}},
+		{`{{ config(enabled=fa`, true, ConfigCompletionContext{Key: "enabled", Prefix: "fa"}},
+		{`{{ ref(`, false, ConfigCompletionContext{}},
+		{`{{ config(materialized='table') }}`, false, ConfigCompletionContext{}},
+	}
+
+	for _, tt := range contexts {
+		configContext, ok := getConfigCompletionContext(tt.content, len(tt.content))
+		if ok != tt.ok || configContext != tt.expected {
+			t.Errorf("%v: expected %v %+v but got %v %+v", tt.content, tt.ok, tt.expected, ok, configContext)
+		}
+	}
+
+	items := getConfigCompletions(ConfigCompletionContext{Prefix: "on_"})
+	if len(items) != 1 || *items[0].InsertText != "on_schema_change='${1|ignore,fail,append_new_columns,sync_all_columns|}'$0" {
+		t.Errorf("expected on_schema_change but got %v", items)
+	}
+
+	items = getConfigCompletions(ConfigCompletionContext{Key: "materialized", Prefix: "in", InString: true})
+	if len(items) != 1 || items[0].Label != "incremental" || items[0].InsertText != nil {
+		t.Errorf("expected incremental but got %v", items)
+	}
+
+	items = getConfigCompletions(ConfigCompletionContext{Key: "materialized"})
+	if len(items) != 5 || *items[0].InsertText != "'view'" {
+		t.Errorf("expected quoted materializations but got %v", items)
+	}
+}
+
+func TestConfigDiagnostics(t *testing.T) {
+	content := "{{ config(materialized='tabel', on_schema_change='drop', enabled='yes', tags='daily', materialzed='table', table_type='iceberg', unique_key=var('key')) }}"
+
+	diagnostics := getConfigDiagnostics(NewJinjaParser(), content)
+	if len(diagnostics) != 5 {
+		t.Fatalf("expected 5 diagnostics but got %v", diagnostics)
+	}
+
+	severities := []protocol.DiagnosticSeverity{}
+	for _, diagnostic := range diagnostics {
+		severities = append(severities, *diagnostic.Severity)
+	}
+
+	// materialized can be a custom materialization so it only warns, a key that
+	// isn't close to a known one may come from an adapter
+	expected := []protocol.DiagnosticSeverity{protocol.DiagnosticSeverityWarning, protocol.DiagnosticSeverityError, protocol.DiagnosticSeverityError, protocol.DiagnosticSeverityWarning, protocol.DiagnosticSeverityInformation}
+	if !slices.Equal(severities, expected) {
+		t.Errorf("expected %v but got %v", expected, severities)
+	}
+
+	if r := diagnostics[3].Range; r.Start.Character != 86 || r.End.Character != 97 || !strings.Contains(diagnostics[3].Message, "'materialized'") {
+		t.Errorf("expected the misspelled key range but got %v", r)
+	}
+
+	if _, ok := findConfigKey("pre-hook"); !ok {
+		t.Errorf("the hyphenated hooks should be known")
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	settings, _ := LoadSettings("./tests/project")
+
+	path := filepath.Join(settings.GetRootDirectory(), "models", "staging", "stg_orders.sql")
+	config := mergeConfigBlocks(settings.GetModelConfigForFile("jaffle_shop", path), NewJinjaParser().GetConfigBlocks(`{{ config(materialized='incremental', tags='orders') }}`))
+
+	if config["materialized"] != "incremental" || config["enabled"] != true {
+		t.Errorf("got wrong config %v", config)
+	}
+
+	if tags := toStringList(config["tags"]); !slices.Equal(tags, []string{"nightly", "staging", "orders"}) {
+		t.Errorf("expected the tags to add up but got %v", tags)
+	}
+
+	if config := settings.GetModelConfigForFile("jaffle_shop", "/elsewhere/model.sql"); len(config) != 0 {
+		t.Errorf("expected no config outside of the model paths but got %v", config)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDefinitionRanges(t *testing.T) {
+	settings, _ := LoadSettings("./tests/project")
+	schemas, err := settings.GetSchemaFiles()
+	if err != nil {
+		t.Fatalf("could not load schema files %v", err)
+	}
+
+	manifest, err := settings.PredictManifestFile(settings.Name, schemas)
+	if err != nil {
+		t.Fatalf("could not predict manifest %v", err)
+	}
+
+	uri := "file://" + filepath.Join(settings.GetRootDirectory(), "models", "orders.sql")
+	content := "select {{ dollars_to_cents('amount') }}\nfrom {{ ref('stg_orders') }}"
+	request := DefinitionRequest{FileUri: uri, Content: content, Manifest: manifest, ProjectName: manifest.Metadata.ProjectName}
+
+	// the second macro of the file
+	request.Position.Character = 12
+	links, err := manifest.Nodes["model.jaffle_shop.orders"].GetDefinition(request)
+	if err != nil || len(links) != 1 {
+		t.Fatalf("expected a macro link but got %v %v", links, err)
+	}
+
+	link := links[0]
+	if link.TargetSelectionRange.Start.Line != 4 || link.TargetSelectionRange.Start.Character != 9 || link.TargetRange.Start.Line != 4 || link.TargetRange.End.Line != 6 {
+		t.Errorf("got wrong macro target %+v", link)
+	}
+	if link.OriginSelectionRange == nil || link.OriginSelectionRange.Start.Character != 10 || link.OriginSelectionRange.End.Character != 26 {
+		t.Errorf("got wrong origin %+v", link.OriginSelectionRange)
+	}
+
+	// a model links to its file and to its schema entry
+	request.Position.Line, request.Position.Character = 1, 15
+	links, _ = manifest.Nodes["model.jaffle_shop.orders"].GetDefinition(request)
+	if len(links) != 2 || !strings.HasSuffix(links[0].TargetURI, "stg_orders.sql") || !strings.HasSuffix(links[1].TargetURI, "schema.yml") {
+		t.Fatalf("expected the model and schema links but got %+v", links)
+	}
+
+	schema := links[1]
+	if schema.TargetSelectionRange.Start.Line != 3 || schema.TargetSelectionRange.Start.Character != 10 || schema.TargetRange.End.Line != 9 {
+		t.Errorf("got wrong schema target %+v", schema)
+	}
+}
+
+func TestSchemaImplementation(t *testing.T) {
+	settings, _ := LoadSettings("./tests/project")
+	schemas, _ := settings.GetSchemaFiles()
+	manifest, err := settings.PredictManifestFile(settings.Name, schemas)
+	if err != nil {
+		t.Fatalf("could not predict manifest %v", err)
+	}
+
+	stgOrders := manifest.Nodes["model.jaffle_shop.stg_orders"]
+	if amount := stgOrders.Columns["amount"]; amount.NameRange.Start.Line != 8 || amount.NameRange.Start.Character != 14 || amount.Range.End.Line != 9 {
+		t.Errorf("got wrong column location %+v", amount)
+	}
+
+	uri := "file://" + filepath.Join(settings.GetRootDirectory(), "models", "staging", "stg_orders.sql")
+	content := "select order_id, amount\nfrom {{ source('raw', 'orders') }}"
+
+	// a column jumps to its entry
+	links := getImplementation(manifest, uri, content, protocol.Position{Line: 0, Character: 19})
+	if len(links) != 1 || !strings.HasSuffix(links[0].TargetURI, "schema.yml") || links[0].TargetSelectionRange.Start.Line != 8 {
+		t.Errorf("expected the amount column but got %+v", links)
+	}
+
+	// anywhere else jumps to the model entry
+	links = getImplementation(manifest, uri, content, protocol.Position{Line: 0, Character: 2})
+	if len(links) != 1 || links[0].TargetSelectionRange.Start.Line != 3 || links[0].OriginSelectionRange != nil {
+		t.Errorf("expected the stg_orders entry but got %+v", links)
+	}
+
+	links, _ = stgOrders.GetDefinition(DefinitionRequest{FileUri: uri, Content: content, Manifest: manifest, ProjectName: "jaffle_shop", Position: protocol.Position{Line: 0, Character: 9}})
+	if len(links) != 1 || links[0].TargetSelectionRange.Start.Line != 6 || links[0].OriginSelectionRange.End.Character != 15 {
+		t.Errorf("expected the order_id column but got %+v", links)
+	}
+}
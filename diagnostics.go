@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// publishDiagnostics publishes the diagnostics of the document. A cycle is
+// reported on every ref that closes it, so the other models on a cycle, and the
+// ones that were on one before this change, are published again as well
+func (w *Workspace) publishDiagnostics(context *glsp.Context, manifest Manifest, uri, content string) {
+	documents := w.Documents.Snapshot()
+	documents[documentKey(uri)] = content
+	graph := getDocumentsDependencyGraph(manifest, documents)
+
+	publishDocumentDiagnostics(context, manifest, graph, uri, content)
+
+	members := getCycleMembers(manifest, graph)
+	w.lock.Lock()
+	previous := w.cycleMembers
+	w.cycleMembers = members
+	w.lock.Unlock()
+
+	published := map[string]bool{documentKey(uri): true}
+	for _, member := range slices.Concat(members, previous) {
+		if published[documentKey(member)] {
+			continue
+		}
+		published[documentKey(member)] = true
+
+		fileContent, err := w.Documents.ReadFile(member)
+		if err != nil {
+			continue
+		}
+		publishDocumentDiagnostics(context, manifest, graph, w.Documents.URI(member), string(fileContent))
+	}
+}
+
+func publishDocumentDiagnostics(context *glsp.Context, manifest Manifest, graph map[string][]string, uri, content string) {
+	diagnosticsLog := commonlog.GetLoggerf("%s.diagnostics", lsName)
+
+	if filepath.Ext(uri) != ".sql" {
+		return
+	}
+
+	parser := NewJinjaParser()
+	diagnostics := getRefDiagnostics(manifest, parser, content)
+	diagnostics = append(diagnostics, getCycleDiagnostics(manifest, graph, parser, uri, content)...)
+	diagnostics = append(diagnostics, getVarDiagnostics(manifest, parser, content)...)
+	diagnostics = append(diagnostics, getConfigDiagnostics(parser, content)...)
+	diagnosticsLog.Infof("publishing %v diagnostics for %v", len(diagnostics), uri)
+
+	context.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+func getRefDiagnostics(manifest Manifest, parser *JinjaParser, content string) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+
+	if !parser.HasJinjaBlocks(content) {
+		return diagnostics
+	}
+
+	severity := protocol.DiagnosticSeverityError
+	source := lsName
+	for _, ref := range parser.GetAllRefTags(content) {
+		if _, ok := manifest.Nodes[manifest.GetRefKey(ref)]; ok {
+			continue
+		}
+
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    getRangeInFile(content, ref.Range),
+			Severity: &severity,
+			Source:   &source,
+			Message:  fmt.Sprintf("could not find model '%s' referenced by ref()", ref.ModelName),
+		})
+	}
+
+	return diagnostics
+}
+
+// getVarDiagnostics warns about var() calls dbt can't resolve at compile time,
+// they have no default and the var isn't defined in dbt_project.yml
+func getVarDiagnostics(manifest Manifest, parser *JinjaParser, content string) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+
+	severity := protocol.DiagnosticSeverityWarning
+	source := lsName
+	for _, reference := range parser.GetAllVarTags(content) {
+		if reference.HasDefault {
+			continue
+		}
+		if _, ok := manifest.FindVar(manifest.Metadata.ProjectName, reference.Name); ok {
+			continue
+		}
+
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    getRangeInFile(content, reference.NameRange),
+			Severity: &severity,
+			Source:   &source,
+			Message:  fmt.Sprintf("var '%s' has no default and is not defined in dbt_project.yml", reference.Name),
+		})
+	}
+
+	return diagnostics
+}
+
+// getCycleDiagnostics reports every ref in the document that points back into a
+// cycle the model is part of. The refs in the document replace the ones in graph
+// so the diagnostics follow unsaved edits
+func getCycleDiagnostics(manifest Manifest, graph map[string][]string, parser *JinjaParser, uri, content string) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+
+	key := fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, getModelNameFromFilePath(uri))
+	if _, ok := manifest.Nodes[key]; !ok {
+		return diagnostics
+	}
+
+	refs := parser.GetAllRefTags(content)
+	graph = maps.Clone(graph)
+	graph[key] = []string{}
+	for _, ref := range refs {
+		graph[key] = append(graph[key], manifest.GetRefKey(ref))
+	}
+
+	var component []string
+	for _, c := range getStronglyConnectedComponents(graph) {
+		if slices.Contains(c, key) {
+			component = c
+			break
+		}
+	}
+	if component == nil {
+		return diagnostics
+	}
+
+	severity := protocol.DiagnosticSeverityError
+	source := lsName
+	for _, ref := range refs {
+		refKey := manifest.GetRefKey(ref)
+		if !slices.Contains(component, refKey) {
+			continue
+		}
+
+		path := getCyclePath(graph, component, key, refKey)
+		names := []string{}
+		related := []protocol.DiagnosticRelatedInformation{}
+		for i, step := range path {
+			names = append(names, manifest.Nodes[step].Name)
+			if i == 0 || i == len(path)-1 {
+				continue
+			}
+
+			related = append(related, protocol.DiagnosticRelatedInformation{
+				Location: getRefLocation(manifest, step, path[i+1]),
+				Message:  fmt.Sprintf("%s refs %s", manifest.Nodes[step].Name, manifest.Nodes[path[i+1]].Name),
+			})
+		}
+
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:              getRangeInFile(content, ref.Range),
+			Severity:           &severity,
+			Source:             &source,
+			Message:            fmt.Sprintf("ref('%s') creates a cycle: %s", ref.ModelName, strings.Join(names, " -> ")),
+			RelatedInformation: related,
+		})
+	}
+
+	return diagnostics
+}
+
+// getDocumentsDependencyGraph is the dependency graph with the refs of the open
+// documents in place of the ones we indexed
+func getDocumentsDependencyGraph(manifest Manifest, documents map[string]string) map[string][]string {
+	graph := getDependencyGraph(manifest)
+	for path, content := range documents {
+		key := fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, getModelNameFromFilePath(path))
+		if filepath.Ext(path) != ".sql" || manifest.Nodes[key].ResourceType != "model" {
+			continue
+		}
+
+		graph[key] = []string{}
+		for _, ref := range NewJinjaParser().GetAllRefTags(content) {
+			graph[key] = append(graph[key], manifest.GetRefKey(ref))
+		}
+	}
+	return graph
+}
+
+// getCycleMembers returns the files of every model that is part of a cycle
+func getCycleMembers(manifest Manifest, graph map[string][]string) []string {
+	members := []string{}
+	for _, component := range getStronglyConnectedComponents(graph) {
+		for _, key := range component {
+			if node, ok := manifest.Nodes[key]; ok && node.OriginalPath != "" {
+				members = append(members, node.OriginalPath)
+			}
+		}
+	}
+	return members
+}
+
+// getRefLocation finds the ref() in the from model that points at the to model
+func getRefLocation(manifest Manifest, from, to string) protocol.Location {
+	for _, reference := range manifest.References[to] {
+		if reference.NodeKey == from {
+			return protocol.Location{URI: reference.FileUri, Range: reference.Range}
+		}
+	}
+	return protocol.Location{URI: manifest.Nodes[from].OriginalPath}
+}
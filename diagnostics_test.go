@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestRefDiagnostics(t *testing.T) {
+	manifest := Manifest{
+		Metadata: Metadata{ProjectName: "test"},
+		Nodes: map[string]Node{
+			"model.test.my_first_dbt_model": {Name: "my_first_dbt_model"},
+		},
+	}
+
+	content := "select *\nfrom {{ ref('my_first_dbt_model') }}\njoin {{ ref('my_frist_dbt_model') }}"
+	diagnostics := getRefDiagnostics(manifest, NewJinjaParser(), content)
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic but got %v", len(diagnostics))
+	}
+
+	r := diagnostics[0].Range
+	if r.Start.Line != 2 || r.Start.Character != 5 || r.End.Line != 2 || r.End.Character != 36 {
+		t.Errorf("got wrong range %v", r)
+	}
+
+	diagnostics = getRefDiagnostics(manifest, NewJinjaParser(), "{{ ref('test', 'my_first_dbt_model') }} {{ ref('other', 'my_first_dbt_model') }}")
+	if len(diagnostics) != 1 {
+		t.Errorf("expected 1 diagnostic for the other package but got %v", len(diagnostics))
+	}
+}
+
+func TestPackageRefs(t *testing.T) {
+	manifest := Manifest{
+		Metadata: Metadata{ProjectName: "test"},
+		Nodes: map[string]Node{
+			"model.test.orders":          {Name: "orders", Depends: Depends{Nodes: []string{"model.test.stg_charges"}}},
+			"model.stripe.stg_charges":   {Name: "stg_charges"},
+			"model.stripe.stg_customers": {Name: "stg_customers"},
+			"model.test.stg_customers":   {Name: "stg_customers"},
+		},
+		References: map[string][]ReferenceLocation{
+			"model.test.stg_charges": {{NodeKey: "model.test.orders"}},
+		},
+	}
+
+	if diagnostics := getRefDiagnostics(manifest, NewJinjaParser(), "{{ ref('stg_charges') }}"); len(diagnostics) != 0 {
+		t.Errorf("a ref to a package model should resolve but got %v", diagnostics)
+	}
+
+	if key := manifest.GetRefKey(ModelReference{ModelName: "stg_customers"}); key != "model.test.stg_customers" {
+		t.Errorf("the root project should win but got %v", key)
+	}
+
+	manifest.resolveRefKeys()
+	if manifest.Nodes["model.test.orders"].Depends.Nodes[0] != "model.stripe.stg_charges" || len(manifest.References["model.stripe.stg_charges"]) != 1 {
+		t.Errorf("expected the dependency to move to the package model but got %v %v", manifest.Nodes["model.test.orders"].Depends, manifest.References)
+	}
+}
+
+func TestVarDiagnostics(t *testing.T) {
+	manifest := Manifest{
+		Metadata: Metadata{ProjectName: "test"},
+		Vars:     []ProjectVar{{Name: "start_date", Value: "2020-01-01"}},
+	}
+
+	content := "{{ var('start_date') }} {{ var('end_date', none) }}\n{{ var('missing') }}"
+	diagnostics := getVarDiagnostics(manifest, NewJinjaParser(), content)
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic but got %v", diagnostics)
+	}
+
+	r := diagnostics[0].Range
+	if *diagnostics[0].Severity != protocol.DiagnosticSeverityWarning || r.Start.Line != 1 || r.Start.Character != 8 || r.End.Character != 15 {
+		t.Errorf("got wrong diagnostic %+v", diagnostics[0])
+	}
+}
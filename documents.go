@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// DocumentStore keeps the text of every buffer the editor has open so that
+// features see unsaved edits instead of what is on disk
+type DocumentStore struct {
+	documents map[string]string
+	// uris keeps the uri the editor opened each document with, clients escape
+	// them in their own way so diagnostics must go back to the same one
+	uris map[string]string
+	lock sync.RWMutex
+}
+
+func NewDocumentStore() *DocumentStore {
+	return &DocumentStore{documents: map[string]string{}, uris: map[string]string{}}
+}
+
+func (ds *DocumentStore) Open(uri, text string) {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	ds.documents[documentKey(uri)] = text
+	ds.uris[documentKey(uri)] = uri
+}
+
+func (ds *DocumentStore) Close(uri string) {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	delete(ds.documents, documentKey(uri))
+	delete(ds.uris, documentKey(uri))
+}
+
+// URI returns the uri the document at uri was opened with, or uri itself when it
+// isn't open
+func (ds *DocumentStore) URI(uri string) string {
+	ds.lock.RLock()
+	defer ds.lock.RUnlock()
+	if openUri, ok := ds.uris[documentKey(uri)]; ok {
+		return openUri
+	}
+	return uri
+}
+
+func (ds *DocumentStore) Get(uri string) (string, bool) {
+	ds.lock.RLock()
+	defer ds.lock.RUnlock()
+	text, ok := ds.documents[documentKey(uri)]
+	return text, ok
+}
+
+// Snapshot returns a copy of every open buffer keyed by file path
+func (ds *DocumentStore) Snapshot() map[string]string {
+	ds.lock.RLock()
+	defer ds.lock.RUnlock()
+
+	snapshot := make(map[string]string, len(ds.documents))
+	for key, text := range ds.documents {
+		snapshot[key] = text
+	}
+	return snapshot
+}
+
+// Change applies the content changes in order and returns the resulting text
+func (ds *DocumentStore) Change(uri string, changes []any) (string, error) {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+
+	key := documentKey(uri)
+	text, ok := ds.documents[key]
+	if !ok {
+		return "", fmt.Errorf("document %v is not open", uri)
+	}
+
+	for _, change := range changes {
+		switch c := change.(type) {
+		case protocol.TextDocumentContentChangeEventWhole:
+			text = c.Text
+		case protocol.TextDocumentContentChangeEvent:
+			start := getRawPositionInFile(text, c.Range.Start.Line, c.Range.Start.Character)
+			end := getRawPositionInFile(text, c.Range.End.Line, c.Range.End.Character)
+			if start > end || end > len(text) {
+				return "", fmt.Errorf("change range %v is outside of document %v", c.Range, uri)
+			}
+			text = text[:start] + c.Text + text[end:]
+		}
+	}
+
+	ds.documents[key] = text
+	return text, nil
+}
+
+// ReadFile returns the open buffer for the uri and falls back to the file on disk
+func (ds *DocumentStore) ReadFile(uri string) ([]byte, error) {
+	if text, ok := ds.Get(uri); ok {
+		return []byte(text), nil
+	}
+	return ReadFileUri(uri)
+}
+
+func documentKey(uri string) string {
+	path, err := CleanUri(uri)
+	if err != nil {
+		return uri
+	}
+	return path
+}
+
+func (w *Workspace) didOpen(context *glsp.Context, params *protocol.DidOpenTextDocumentParams) error {
+	manifest := w.Manifest()
+	w.Documents.Open(params.TextDocument.URI, params.TextDocument.Text)
+	w.publishDiagnostics(context, manifest, params.TextDocument.URI, params.TextDocument.Text)
+	return nil
+}
+
+func (w *Workspace) didChange(context *glsp.Context, params *protocol.DidChangeTextDocumentParams) error {
+	manifest := w.Manifest()
+	text, err := w.Documents.Change(params.TextDocument.URI, params.ContentChanges)
+	if err != nil {
+		return err
+	}
+
+	w.publishDiagnostics(context, manifest, params.TextDocument.URI, text)
+	return nil
+}
+
+func (w *Workspace) didSave(context *glsp.Context, params *protocol.DidSaveTextDocumentParams) error {
+	manifest := w.Manifest()
+	if params.Text != nil {
+		w.publishDiagnostics(context, manifest, params.TextDocument.URI, *params.Text)
+		return nil
+	}
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		return nil
+	}
+	w.publishDiagnostics(context, manifest, params.TextDocument.URI, string(fileContent))
+	return nil
+}
+
+func (w *Workspace) didClose(context *glsp.Context, params *protocol.DidCloseTextDocumentParams) error {
+	w.Documents.Close(params.TextDocument.URI)
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDocumentIncrementalChange(t *testing.T) {
+	store := NewDocumentStore()
+	store.Open("file:///project/models/orders.sql", "select *\nfrom {{ ref('customers') }}")
+
+	text, err := store.Change("file:///project/models/orders.sql", []any{
+		protocol.TextDocumentContentChangeEvent{
+			Range: &protocol.Range{
+				Start: protocol.Position{Line: 1, Character: 13},
+				End:   protocol.Position{Line: 1, Character: 22},
+			},
+			Text: "stg_customers",
+		},
+	})
+	if err != nil {
+		t.Fatalf("error %v", err)
+	}
+
+	expected := "select *\nfrom {{ ref('stg_customers') }}"
+	if text != expected {
+		t.Errorf("expected %v but got %v", expected, text)
+	}
+
+	stored, _ := store.ReadFile("/project/models/orders.sql")
+	if string(stored) != expected {
+		t.Errorf("store did not keep the change, got %v", string(stored))
+	}
+
+	store.Close("file:///project/models/orders.sql")
+	if _, ok := store.Get("file:///project/models/orders.sql"); ok {
+		t.Errorf("document should be closed")
+	}
+}
+
+func TestPositionsUseUtf16(t *testing.T) {
+	content := "-- 🦆 duck\n{{ ref('ducks') }}"
+
+	raw := getRawPositionInFile(content, 0, 6)
+	if content[raw:raw+4] != "duck" {
+		t.Errorf("got wrong raw position %v", raw)
+	}
+
+	position := getPositionInFile(content, raw)
+	if position.Line != 0 || position.Character != 6 {
+		t.Errorf("got wrong position %v", position)
+	}
+
+	position = getPositionInFile(content, len(content))
+	if position.Line != 1 || position.Character != 18 {
+		t.Errorf("got wrong end position %v", position)
+	}
+}
+
+func TestDocumentKeepsOpenUri(t *testing.T) {
+	store := NewDocumentStore()
+	store.Open("file:///c%3A/project/models/orders.sql", "select 1")
+
+	if uri := store.URI("/c:/project/models/orders.sql"); uri != "file:///c%3A/project/models/orders.sql" {
+		t.Errorf("expected the uri the document was opened with but got %v", uri)
+	}
+	if uri := store.URI("file:///project/models/customers.sql"); uri != "file:///project/models/customers.sql" {
+		t.Errorf("expected a closed document to keep its uri but got %v", uri)
+	}
+}
@@ -40,3 +40,17 @@ func TestFileURI(t *testing.T) {
 		fmt.Printf("url:%v\nscheme:%v host:%v Path:%v\n\n", u, u.Scheme, u.Host, u.Path)
 	}
 }
+
+func TestCleanUri(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"file:///root/dev/dbt/file.sql": "/root/dev/dbt/file.sql",
+		"/root/dev/dbt/file.sql":        "/root/dev/dbt/file.sql",
+		"file:///c:/dev/dbt/file.sql":   "c:/dev/dbt/file.sql",
+		"file:///C%3A/dev/dbt/file.sql": "C:/dev/dbt/file.sql",
+	} {
+		cleaned, _ := CleanUri(uri)
+		if cleaned != expected {
+			t.Errorf("expected %v but got %v", expected, cleaned)
+		}
+	}
+}
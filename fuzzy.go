@@ -0,0 +1,87 @@
+package main
+
+import "strings"
+
+// fuzzyScore matches query against candidate as a case insensitive subsequence.
+// Matches at the start of the candidate, at word boundaries and runs of
+// consecutive characters score higher, skipped characters cost a little
+func fuzzyScore(query, candidate string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+
+	query = strings.ToLower(query)
+	lowerCandidate := strings.ToLower(candidate)
+
+	score := 0
+	queryIndex := 0
+	previousMatch := -1
+
+	for i := 0; i < len(lowerCandidate) && queryIndex < len(query); i++ {
+		if lowerCandidate[i] != query[queryIndex] {
+			continue
+		}
+
+		switch {
+		case i == 0:
+			score += 8
+		case isWordBoundary(candidate, i):
+			score += 6
+		}
+
+		if previousMatch != -1 && previousMatch == i-1 {
+			score += 8
+		} else if previousMatch != -1 {
+			score -= min(3*(i-previousMatch-1), 10)
+		}
+
+		score += 1
+		previousMatch = i
+		queryIndex++
+	}
+
+	if queryIndex != len(query) {
+		return 0, false
+	}
+
+	if lowerCandidate == query {
+		score += 50
+	}
+
+	// prefer the shorter name when everything else is equal
+	return score*100 - len(candidate), true
+}
+
+func isWordBoundary(candidate string, index int) bool {
+	previous := candidate[index-1]
+	if previous == '_' || previous == '.' || previous == '-' || previous == ' ' {
+		return true
+	}
+
+	// camelCase
+	current := candidate[index]
+	return previous >= 'a' && previous <= 'z' && current >= 'A' && current <= 'Z'
+}
+
+// editDistance is the number of single character insertions, deletions and
+// substitutions it takes to turn a into b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
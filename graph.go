@@ -0,0 +1,157 @@
+package main
+
+import (
+	"slices"
+)
+
+// getDependencyGraph returns the edges from each node to the nodes it depends on
+func getDependencyGraph(manifest Manifest) map[string][]string {
+	graph := map[string][]string{}
+	for key, node := range manifest.Nodes {
+		graph[key] = node.Depends.Nodes
+	}
+	return graph
+}
+
+// getStronglyConnectedComponents runs tarjan's algorithm over the graph and only
+// returns the components that form a cycle
+func getStronglyConnectedComponents(graph map[string][]string) [][]string {
+	index := 0
+	indices := map[string]int{}
+	lowLinks := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	components := [][]string{}
+
+	var connect func(key string)
+	connect = func(key string) {
+		indices[key] = index
+		lowLinks[key] = index
+		index++
+		stack = append(stack, key)
+		onStack[key] = true
+
+		for _, dependency := range graph[key] {
+			if _, visited := indices[dependency]; !visited {
+				connect(dependency)
+				lowLinks[key] = min(lowLinks[key], lowLinks[dependency])
+			} else if onStack[dependency] {
+				lowLinks[key] = min(lowLinks[key], indices[dependency])
+			}
+		}
+
+		if lowLinks[key] != indices[key] {
+			return
+		}
+
+		component := []string{}
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == key {
+				break
+			}
+		}
+
+		if len(component) > 1 || slices.Contains(graph[key], key) {
+			slices.Sort(component)
+			components = append(components, component)
+		}
+	}
+
+	keys := []string{}
+	for key := range graph {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		if _, visited := indices[key]; !visited {
+			connect(key)
+		}
+	}
+
+	return components
+}
+
+// getCyclePath finds the shortest path that starts with the edge from -> to and
+// leads back to from, staying inside the component
+func getCyclePath(graph map[string][]string, component []string, from, to string) []string {
+	previous := map[string]string{to: ""}
+	queue := []string{to}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current == from {
+			path := []string{}
+			for step := current; step != ""; step = previous[step] {
+				path = append([]string{step}, path...)
+			}
+			return append([]string{from}, path...)
+		}
+
+		for _, dependency := range graph[current] {
+			if _, seen := previous[dependency]; seen || !slices.Contains(component, dependency) {
+				continue
+			}
+			previous[dependency] = current
+			queue = append(queue, dependency)
+		}
+	}
+
+	return nil
+}
+
+// getChildMap inverts the dependency graph, it maps each node to the nodes that
+// depend on it
+func getChildMap(manifest Manifest) map[string][]string {
+	children := map[string][]string{}
+	for key, node := range manifest.Nodes {
+		for _, parent := range node.Depends.Nodes {
+			if !slices.Contains(children[parent], key) {
+				children[parent] = append(children[parent], key)
+			}
+		}
+	}
+
+	for _, keys := range children {
+		slices.Sort(keys)
+	}
+	return children
+}
+
+// getLineage returns every node and source the node with key is built from and
+// every node built on it, directly or not
+func getLineage(manifest Manifest, key string) ([]string, []string) {
+	walk := func(graph map[string][]string) []string {
+		seen := map[string]bool{key: true}
+		pending := slices.Clone(graph[key])
+		found := []string{}
+		for len(pending) > 0 {
+			current := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			if seen[current] {
+				continue
+			}
+			seen[current] = true
+
+			_, isNode := manifest.Nodes[current]
+			_, isSource := manifest.Sources[current]
+			if !isNode && !isSource {
+				continue
+			}
+
+			found = append(found, current)
+			pending = append(pending, graph[current]...)
+		}
+
+		slices.Sort(found)
+		return found
+	}
+
+	return walk(getDependencyGraph(manifest)), walk(getChildMap(manifest))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	graph := map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"a"},
+		"d": {"a"},
+		"e": {"e"},
+	}
+
+	components := getStronglyConnectedComponents(graph)
+	if len(components) != 2 {
+		t.Fatalf("expected 2 cycles but got %v", components)
+	}
+
+	if !slices.Equal(components[0], []string{"a", "b", "c"}) || !slices.Equal(components[1], []string{"e"}) {
+		t.Errorf("got wrong components %v", components)
+	}
+
+	path := getCyclePath(graph, components[0], "c", "a")
+	if !slices.Equal(path, []string{"c", "a", "b", "c"}) {
+		t.Errorf("got wrong path %v", path)
+	}
+}
+
+func TestCycleDiagnostics(t *testing.T) {
+	manifest := Manifest{
+		Metadata: Metadata{ProjectName: "test"},
+		Nodes: map[string]Node{
+			"model.test.orders":    {Name: "orders", Depends: Depends{Nodes: []string{"model.test.customers"}}},
+			"model.test.customers": {Name: "customers", Depends: Depends{Nodes: []string{"model.test.payments"}}},
+			"model.test.payments":  {Name: "payments"},
+		},
+	}
+
+	content := "select * from {{ ref('orders') }}"
+	diagnostics := getCycleDiagnostics(manifest, getDependencyGraph(manifest), NewJinjaParser(), "file:///project/models/payments.sql", content)
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic but got %v", len(diagnostics))
+	}
+
+	expected := "ref('orders') creates a cycle: payments -> orders -> customers -> payments"
+	if diagnostics[0].Message != expected {
+		t.Errorf("expected %v but got %v", expected, diagnostics[0].Message)
+	}
+
+	if len(diagnostics[0].RelatedInformation) != 2 {
+		t.Errorf("expected 2 related locations but got %v", len(diagnostics[0].RelatedInformation))
+	}
+
+	diagnostics = getCycleDiagnostics(manifest, getDependencyGraph(manifest), NewJinjaParser(), "file:///project/models/payments.sql", "select 1")
+	if len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics once the ref is removed but got %v", diagnostics)
+	}
+}
+
+func TestCycleMembers(t *testing.T) {
+	manifest := Manifest{
+		Metadata: Metadata{ProjectName: "test"},
+		Nodes: map[string]Node{
+			"model.test.orders":    {Name: "orders", ResourceType: "model", OriginalPath: "file:///project/models/orders.sql", Depends: Depends{Nodes: []string{"model.test.customers"}}},
+			"model.test.customers": {Name: "customers", ResourceType: "model", OriginalPath: "file:///project/models/customers.sql", Depends: Depends{Nodes: []string{"model.test.payments"}}},
+			"model.test.payments":  {Name: "payments", ResourceType: "model", OriginalPath: "file:///project/models/payments.sql"},
+		},
+	}
+
+	graph := getDocumentsDependencyGraph(manifest, map[string]string{"/project/models/payments.sql": "select * from {{ ref('orders') }}"})
+	members := getCycleMembers(manifest, graph)
+	slices.Sort(members)
+	expected := []string{"file:///project/models/customers.sql", "file:///project/models/orders.sql", "file:///project/models/payments.sql"}
+	if !slices.Equal(members, expected) {
+		t.Errorf("expected every model on the cycle but got %v", members)
+	}
+
+	// the other models on the cycle get a diagnostic on the ref that closes it
+	diagnostics := getCycleDiagnostics(manifest, graph, NewJinjaParser(), "file:///project/models/orders.sql", "select * from {{ ref('customers') }}")
+	if len(diagnostics) != 1 || diagnostics[0].Message != "ref('customers') creates a cycle: orders -> customers -> payments -> orders" {
+		t.Errorf("expected the cycle on orders but got %v", diagnostics)
+	}
+
+	if members := getCycleMembers(manifest, getDependencyGraph(manifest)); len(members) != 0 {
+		t.Errorf("expected no cycle without the open document but got %v", members)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// implementationHandler jumps from a model file to where it's documented, the
+// schema yaml entry of the ref, source or column under the cursor or else of the
+// model itself
+func (w *Workspace) implementationHandler(context *glsp.Context, params *protocol.ImplementationParams) (any, error) {
+	implementationLog := commonlog.GetLoggerf("%s.implementation", lsName)
+	manifest := w.Manifest()
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		implementationLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	links := getImplementation(manifest, params.TextDocument.URI, string(fileContent), params.Position)
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	implementationLog.Infof("found implementation %v", links[0].TargetURI)
+	return links, nil
+}
+
+func getImplementation(manifest Manifest, uri, content string, position protocol.Position) []protocol.LocationLink {
+	parser := NewJinjaParser()
+	rawPosition := getRawPositionInFile(content, position.Line, position.Character)
+	projectName := manifest.Metadata.ProjectName
+
+	for _, ref := range parser.GetAllRefTags(content) {
+		if rawPosition < ref.Range.Start || rawPosition > ref.Range.End {
+			continue
+		}
+
+		node, ok := manifest.Nodes[manifest.GetRefKey(ref)]
+		if !ok || node.SchemaPath == "" {
+			return nil
+		}
+
+		origin := getRangeInFile(content, ref.NameRange)
+		return []protocol.LocationLink{{
+			OriginSelectionRange: &origin,
+			TargetURI:            node.SchemaPath,
+			TargetRange:          node.SchemaRange,
+			TargetSelectionRange: node.SchemaNameRange,
+		}}
+	}
+
+	for _, tag := range parser.GetAllSourceTags(content) {
+		if rawPosition < tag.Range.Start || rawPosition > tag.Range.End {
+			continue
+		}
+
+		_, source, ok := manifest.FindSource(tag.SourceName, tag.TableName)
+		if !ok {
+			return nil
+		}
+
+		origin := getRangeInFile(content, Range{Start: tag.SourceRange.Start, End: tag.TableRange.End})
+		return []protocol.LocationLink{{
+			OriginSelectionRange: &origin,
+			TargetURI:            source.OriginalPath,
+			TargetRange:          source.Range,
+			TargetSelectionRange: source.NameRange,
+		}}
+	}
+
+	nodeKey := fmt.Sprintf("model.%s.%s", projectName, getModelNameFromFilePath(uri))
+	node, ok := manifest.Nodes[nodeKey]
+	if !ok {
+		return nil
+	}
+
+	if !positionWithinRange(rawPosition, parser.GetJinjaPositions(content)) {
+		links, _ := node.getColumnDefinition(DefinitionRequest{Manifest: manifest, ProjectName: projectName, Position: position}, content)
+		if len(links) > 0 {
+			return links
+		}
+	}
+
+	if node.SchemaPath == "" {
+		return nil
+	}
+
+	return []protocol.LocationLink{{
+		TargetURI:            node.SchemaPath,
+		TargetRange:          node.SchemaRange,
+		TargetSelectionRange: node.SchemaNameRange,
+	}}
+}
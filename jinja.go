@@ -1,150 +1,299 @@
 package main
 
 import (
-	"regexp"
 	"slices"
 	"strings"
+
+	"github.com/joro550/dbt-language-server/jinja"
 )
 
+// JinjaParser keeps the template it parsed last, a request shares one parser
+// between the helpers so the document is only parsed once
 type JinjaParser struct {
-	expressionPattern      *regexp.Regexp
-	statementPattern       *regexp.Regexp
-	commentPattern         *regexp.Regexp
-	refPattern             *regexp.Regexp
-	macroPattern           *regexp.Regexp
-	effectiveJinjaPattern  *regexp.Regexp
-	macroDefinitionPattern *regexp.Regexp
+	content string
+	file    *jinja.File
+}
+
+func NewJinjaParser() *JinjaParser {
+	return &JinjaParser{}
 }
 
-func NewJinjaParser() JinjaParser {
-	expressionPattern := regexp.MustCompile(`{{[\s\S]*?}}`)
-	statementPattern := regexp.MustCompile(`{%[\s\S]*?%}`)
-	commentPattern := regexp.MustCompile(`{#[\s\S]*?#}`)
-	effectiveJinjaPattern := regexp.MustCompile(`{{[\s\S]*?}}|{%[\s\S]*?%}`)
-	refPattern := regexp.MustCompile(`{{\s*ref\s*\(\s*['|"](?<project>[a-z_]*?)\s*['|"]\s*(,?\s*['|"](?<model>[a-z_]*?)\s*['|"])?\)\s*}}`)
-	macroPattern := regexp.MustCompile(`{{\s*(?<function_name>[a-zA-Z_]*)\s*\([\sA-Za-z=,'_0-9"]*\)\s*}}`)
-	macroDefition := regexp.MustCompile(`{%-?\s*macro\s*(?<function_name>[a-zA-Z_]*)\s*\([\sA-Za-z=,'_0-9"]*\)\s*-?%}`)
-
-	return JinjaParser{
-		expressionPattern:      expressionPattern,
-		statementPattern:       statementPattern,
-		commentPattern:         commentPattern,
-		refPattern:             refPattern,
-		macroPattern:           macroPattern,
-		effectiveJinjaPattern:  effectiveJinjaPattern,
-		macroDefinitionPattern: macroDefition,
+func (jp *JinjaParser) parse(content string) *jinja.File {
+	if jp.file == nil || jp.content != content {
+		jp.file, _ = jinja.ParseTemplate(content)
+		jp.content = content
 	}
+	return jp.file
 }
 
-func (jp JinjaParser) HasJinjaBlocks(content string) bool {
+func (jp *JinjaParser) HasJinjaBlocks(content string) bool {
 	return strings.Contains(content, "{") && strings.Contains(content, "}")
 }
 
-func (jp JinjaParser) GetJinjaPositions(content string) []Range {
-	resultIndicies := jp.effectiveJinjaPattern.FindAllStringIndex(content, -1)
-
-	if resultIndicies == nil {
-		return []Range{}
-	}
-
+// GetJinjaPositions returns the span of every {{ }} and {% %} tag, comments are left out
+func (jp *JinjaParser) GetJinjaPositions(content string) []Range {
+	lexer := jinja.NewJinjaLexer(content)
 	ranges := []Range{}
-	for _, result := range resultIndicies {
-		ranges = append(ranges, Range{Start: result[0], End: result[1]})
+
+	start := -1
+	for token := lexer.NextToken(); token.Token != jinja.EOF; token = lexer.NextToken() {
+		switch token.Token {
+		case jinja.START_EXPRESSION, jinja.START_STATEMENT:
+			start = token.Start
+		case jinja.END_EXPRESSION, jinja.END_STATEMENT:
+			if start != -1 {
+				ranges = append(ranges, Range{Start: start, End: token.End})
+				start = -1
+			}
+		}
 	}
 	return ranges
 }
 
-func (jp JinjaParser) GetAllRefTags(content string) []ModelReference {
-	resultIndicies := jp.refPattern.FindAllStringIndex(content, -1)
-
-	if resultIndicies == nil {
-		return []ModelReference{}
-	}
-
-	modelIndex := jp.refPattern.SubexpIndex("model")
-	projectIndex := jp.refPattern.SubexpIndex("project")
-	matches := jp.refPattern.FindAllStringSubmatch(content, -1)
-
+func (jp *JinjaParser) GetAllRefTags(content string) []ModelReference {
 	references := []ModelReference{}
 
-	for i, index := range resultIndicies {
+	for _, match := range jinja.FindCalls(jp.parse(content), "ref") {
+		arguments := stringArguments(match.Call)
+		if len(arguments) == 0 || len(arguments) > 2 {
+			continue
+		}
 
-		modelName := ""
-		if matches[i][modelIndex] != "" {
-			modelName = matches[i][modelIndex]
-		} else {
-			modelName = matches[i][projectIndex]
+		name, packageName := arguments[0], ""
+		// ref('package', 'model')
+		if len(arguments) == 2 {
+			name, packageName = arguments[1], arguments[0].Value
 		}
 
+		nameSpan := name.ValueSpan()
 		references = append(references, ModelReference{
-			ModelName: modelName,
-			Range:     Range{Start: index[0], End: index[1]},
+			ModelName: name.Value,
+			Package:   packageName,
+			Range:     Range{Start: match.Tag.Start, End: match.Tag.End},
+			NameRange: Range{Start: nameSpan.Start, End: nameSpan.End},
 		})
 	}
 
 	return references
 }
 
-func (jp JinjaParser) GetMacros(content string) []MacroReference {
-	keywords := []string{"ref", "config"}
-	resultIndicies := jp.macroPattern.FindAllStringIndex(content, -1)
+func (jp *JinjaParser) GetAllSourceTags(content string) []SourceReference {
+	references := []SourceReference{}
+
+	for _, match := range jinja.FindCalls(jp.parse(content), "source") {
+		arguments := stringArguments(match.Call)
+		if len(arguments) != 2 {
+			continue
+		}
 
-	if resultIndicies == nil {
-		return []MacroReference{}
+		sourceSpan, tableSpan := arguments[0].ValueSpan(), arguments[1].ValueSpan()
+		references = append(references, SourceReference{
+			SourceName:  arguments[0].Value,
+			TableName:   arguments[1].Value,
+			Range:       Range{Start: match.Tag.Start, End: match.Tag.End},
+			SourceRange: Range{Start: sourceSpan.Start, End: sourceSpan.End},
+			TableRange:  Range{Start: tableSpan.Start, End: tableSpan.End},
+		})
 	}
 
-	macroNames := []MacroReference{}
-	functionIndex := jp.macroPattern.SubexpIndex("function_name")
-	matches := jp.macroPattern.FindAllStringSubmatch(content, -1)
+	return references
+}
 
-	for i, match := range matches {
+func (jp *JinjaParser) GetAllVarTags(content string) []VarReference {
+	references := []VarReference{}
 
-		functionName := match[functionIndex]
-		if functionName == "" || slices.Contains(keywords, functionName) {
+	for _, match := range jinja.FindCalls(jp.parse(content), "var") {
+		if len(match.Call.Arguments) == 0 || len(match.Call.Arguments) > 2 {
 			continue
 		}
 
-		macroNames = append(macroNames, MacroReference{
-			ModelName: functionName,
-			Range: Range{
-				Start: resultIndicies[i][0],
-				End:   resultIndicies[i][1],
-			},
-		})
+		name, ok := match.Call.Arguments[0].(*jinja.StringExpression)
+		if !ok {
+			continue
+		}
 
+		nameSpan := name.ValueSpan()
+		reference := VarReference{
+			Name:      name.Value,
+			Range:     Range{Start: match.Tag.Start, End: match.Tag.End},
+			NameRange: Range{Start: nameSpan.Start, End: nameSpan.End},
+		}
+
+		if len(match.Call.Arguments) == 2 {
+			reference.Default = match.Call.Arguments[1].String()
+			reference.HasDefault = true
+		}
+		references = append(references, reference)
 	}
 
-	return macroNames
+	return references
 }
 
-func (jp JinjaParser) GetMacroDefinitions(content string) []MacroReference {
-	keywords := []string{"ref", "config"}
-	resultIndicies := jp.macroDefinitionPattern.FindAllStringIndex(content, -1)
+func (jp *JinjaParser) GetConfigBlocks(content string) []ConfigReference {
+	references := []ConfigReference{}
+
+	for _, match := range jinja.FindCalls(jp.parse(content), "config") {
+		reference := ConfigReference{Range: Range{Start: match.Tag.Start, End: match.Tag.End}}
+		for _, keyword := range match.Call.Keywords {
+			value, isLiteral := literalValue(keyword.Value)
+			reference.Arguments = append(reference.Arguments, ConfigArgument{
+				Key:        keyword.Name.Value,
+				KeyRange:   Range{Start: keyword.Name.Start, End: keyword.Name.End},
+				Value:      value,
+				IsLiteral:  isLiteral,
+				Expression: keyword.Value.String(),
+				ValueRange: Range{Start: keyword.Value.GetSpan().Start, End: keyword.Value.GetSpan().End},
+			})
+		}
+		references = append(references, reference)
+	}
 
-	if resultIndicies == nil {
-		return []MacroReference{}
+	return references
+}
+
+// literalValue evaluates expressions that don't depend on the jinja context, e.g.
+// 'table' or ['a', 'b'], calls and variables can't be known before dbt compiles
+func literalValue(expression jinja.Expression) (any, bool) {
+	switch value := expression.(type) {
+	case *jinja.StringExpression:
+		return value.Value, true
+	case *jinja.IntegerExpression:
+		return int(value.Value), true
+	case *jinja.FloatExpression:
+		return value.Value, true
+	case *jinja.BooleanExpression:
+		return value.Value, true
+	case *jinja.NoneExpression:
+		return nil, true
+	case *jinja.ListExpression, *jinja.TupleExpression:
+		var elements []jinja.Expression
+		if list, ok := value.(*jinja.ListExpression); ok {
+			elements = list.Elements
+		} else {
+			elements = value.(*jinja.TupleExpression).Elements
+		}
+
+		list := []any{}
+		for _, element := range elements {
+			item, ok := literalValue(element)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, item)
+		}
+		return list, true
+	case *jinja.DictExpression:
+		dict := map[string]any{}
+		for _, pair := range value.Pairs {
+			key, ok := pair.Key.(*jinja.StringExpression)
+			if !ok {
+				return nil, false
+			}
+			item, ok := literalValue(pair.Value)
+			if !ok {
+				return nil, false
+			}
+			dict[key.Value] = item
+		}
+		return dict, true
 	}
+	return nil, false
+}
 
+// stringArguments returns the positional arguments of a call when every one of
+// them is a string literal
+func stringArguments(call *jinja.CallExpression) []*jinja.StringExpression {
+	arguments := []*jinja.StringExpression{}
+	for _, argument := range call.Arguments {
+		value, ok := argument.(*jinja.StringExpression)
+		if !ok {
+			return nil
+		}
+		arguments = append(arguments, value)
+	}
+	return arguments
+}
+
+func (jp *JinjaParser) GetMacros(content string) []MacroReference {
+	keywords := []string{"ref", "source", "config", "var"}
 	macroNames := []MacroReference{}
-	functionIndex := jp.macroDefinitionPattern.SubexpIndex("function_name")
-	matches := jp.macroDefinitionPattern.FindAllStringSubmatch(content, -1)
 
-	for i, match := range matches {
+	for _, match := range jinja.FindCalls(jp.parse(content)) {
+		reference := MacroReference{
+			Range:     Range{Start: match.Tag.Start, End: match.Tag.End},
+			NameRange: Range{Start: match.Call.Function.GetSpan().Start, End: match.Call.Function.GetSpan().End},
+		}
 
-		functionName := match[functionIndex]
-		if functionName == "" || slices.Contains(keywords, functionName) {
+		switch function := match.Call.Function.(type) {
+		case *jinja.Identifier:
+			if slices.Contains(keywords, function.Value) {
+				continue
+			}
+			reference.ModelName = function.Value
+
+		// package.macro()
+		case *jinja.AttributeExpression:
+			packageName, ok := function.Object.(*jinja.Identifier)
+			if !ok {
+				continue
+			}
+			reference.Package = packageName.Value
+			reference.ModelName = function.Attribute.Value
+
+		default:
 			continue
 		}
 
+		macroNames = append(macroNames, reference)
+	}
+
+	return macroNames
+}
+
+func (jp *JinjaParser) GetMacroDefinitions(content string) []MacroReference {
+	macroNames := []MacroReference{}
+
+	for _, macro := range jinja.FindMacros(jp.parse(content)) {
+		if macro.Keyword != "macro" {
+			continue
+		}
+
+		arguments := []MacroArgument{}
+		for _, parameter := range macro.Parameters {
+			argument := MacroArgument{Name: parameter.Name.Value}
+			if parameter.Default != nil {
+				argument.Default = parameter.Default.String()
+			}
+			arguments = append(arguments, argument)
+		}
+
 		macroNames = append(macroNames, MacroReference{
-			ModelName: functionName,
-			Range: Range{
-				Start: resultIndicies[i][0],
-				End:   resultIndicies[i][1],
-			},
+			ModelName: macro.Name.Value,
+			Range:     Range{Start: macro.Start, End: macro.End},
+			NameRange: Range{Start: macro.Name.Start, End: macro.Name.End},
+			Arguments: arguments,
 		})
-
 	}
 
 	return macroNames
 }
+
+func (jp *JinjaParser) GetSnapshotDefinitions(content string) []MacroReference {
+	snapshots := []MacroReference{}
+
+	jinja.Walk(jp.parse(content), func(node jinja.Node) bool {
+		block, ok := node.(*jinja.BlockStatement)
+		if !ok || block.Keyword != "snapshot" {
+			return true
+		}
+
+		snapshots = append(snapshots, MacroReference{
+			ModelName: block.Name.Value,
+			Range:     Range{Start: block.Start, End: block.End},
+			NameRange: Range{Start: block.Name.Start, End: block.Name.End},
+		})
+		return false
+	})
+
+	return snapshots
+}
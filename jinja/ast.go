@@ -3,11 +3,13 @@ package jinja
 import (
 	"bytes"
 	"fmt"
+	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	GetSpan() Span
 }
 
 type Statement interface {
@@ -20,9 +22,24 @@ type Expression interface {
 	expressionNode()
 }
 
+// Span holds the byte offsets a node covers in the source
+type Span struct {
+	Start int
+	End   int
+}
+
+func (s Span) GetSpan() Span { return s }
+
+// Contains reports whether the offset falls inside the span, the end is inclusive
+// so a cursor sitting right after a node still counts
+func (s Span) Contains(offset int) bool {
+	return offset >= s.Start && offset <= s.End
+}
+
 // Node
 type File struct {
 	Statements []Statement
+	Span
 }
 
 func (f *File) TokenLiteral() string {
@@ -42,20 +59,83 @@ func (f *File) String() string {
 }
 
 // Statement
+type TextStatement struct {
+	Value string
+	Token Token
+	Span
+}
+
+func (ts *TextStatement) statementNode()       {}
+func (ts *TextStatement) TokenLiteral() string { return ts.Token.Value }
+func (ts *TextStatement) String() string       { return ts.Value }
+
+type CommentStatement struct {
+	Value string
+	Token Token
+	Span
+}
+
+func (cs *CommentStatement) statementNode()       {}
+func (cs *CommentStatement) TokenLiteral() string { return cs.Token.Value }
+func (cs *CommentStatement) String() string       { return "{#" + cs.Value + "#}" }
+
+type RawStatement struct {
+	Value string
+	Token Token
+	Span
+}
+
+func (rs *RawStatement) statementNode()       {}
+func (rs *RawStatement) TokenLiteral() string { return rs.Token.Value }
+func (rs *RawStatement) String() string {
+	return "{% raw %}" + rs.Value + "{% endraw %}"
+}
+
 type SetStatment struct {
 	Value Expression
 	Name  *Identifier
+	// Names holds every target of a tuple assignment like {% set a, b = 1, 2 %},
+	// Name is the first of them
+	Names []*Identifier
+	// Attribute is set for namespace assignments like {% set ns.total = 1 %}
+	Attribute *Identifier
+	// Body is set instead of Value for {% set name %}...{% endset %}
+	Body  []Statement
 	Token Token
+	Span
 }
 
 func (ss *SetStatment) statementNode()       {}
 func (ss *SetStatment) TokenLiteral() string { return ss.Token.Value }
 
+// Targets returns the names the statement assigns to
+func (ss *SetStatment) Targets() []*Identifier {
+	if len(ss.Names) > 0 {
+		return ss.Names
+	}
+	return []*Identifier{ss.Name}
+}
+
 func (ss *SetStatment) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ss.TokenLiteral() + " ")
-	out.WriteString(ss.Name.String())
+	names := []string{}
+	for _, name := range ss.Targets() {
+		names = append(names, name.String())
+	}
+	out.WriteString(strings.Join(names, ", "))
+	if ss.Attribute != nil {
+		out.WriteString("." + ss.Attribute.String())
+	}
+
+	if ss.Body != nil {
+		out.WriteString(";")
+		out.WriteString(statementsString(ss.Body))
+		out.WriteString("endset;")
+		return out.String()
+	}
+
 	out.WriteString(" = ")
 
 	if ss.Value != nil {
@@ -67,19 +147,189 @@ func (ss *SetStatment) String() string {
 	return out.String()
 }
 
+// ExpressionStatement is a {{ }} block
 type ExpressionStatement struct {
 	Value Expression
 	Token Token
+	Span
 }
 
 func (i *ExpressionStatement) statementNode()       {}
 func (i *ExpressionStatement) TokenLiteral() string { return i.Token.Value }
-func (es *ExpressionStatement) String() string      { return es.Value.String() }
+func (es *ExpressionStatement) String() string {
+	if es.Value == nil {
+		return ""
+	}
+	return es.Value.String()
+}
+
+// DoStatement is a {% do %} block
+type DoStatement struct {
+	Value Expression
+	Token Token
+	Span
+}
+
+func (ds *DoStatement) statementNode()       {}
+func (ds *DoStatement) TokenLiteral() string { return ds.Token.Value }
+func (ds *DoStatement) String() string {
+	if ds.Value == nil {
+		return "do;"
+	}
+	return "do " + ds.Value.String() + ";"
+}
+
+type IfBranch struct {
+	Condition   Expression
+	Consequence []Statement
+	Span
+}
+
+type IfStatement struct {
+	// Branches holds the if followed by every elif
+	Branches    []*IfBranch
+	Alternative []Statement
+	Token       Token
+	Span
+}
+
+func (is *IfStatement) statementNode()       {}
+func (is *IfStatement) TokenLiteral() string { return is.Token.Value }
+func (is *IfStatement) String() string {
+	var out bytes.Buffer
+
+	for i, branch := range is.Branches {
+		if i == 0 {
+			out.WriteString("if ")
+		} else {
+			out.WriteString("elif ")
+		}
+		out.WriteString(branch.Condition.String() + ";")
+		out.WriteString(statementsString(branch.Consequence))
+	}
+
+	if is.Alternative != nil {
+		out.WriteString("else;")
+		out.WriteString(statementsString(is.Alternative))
+	}
+
+	out.WriteString("endif;")
+	return out.String()
+}
+
+type ForStatement struct {
+	Targets   []*Identifier
+	Iterable  Expression
+	Condition Expression
+	Recursive bool
+	Body      []Statement
+	// Alternative is rendered when the iterable is empty
+	Alternative []Statement
+	Token       Token
+	Span
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Value }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for " + joinExpressions(identifiersToExpressions(fs.Targets)) + " in " + fs.Iterable.String())
+	if fs.Condition != nil {
+		out.WriteString(" if " + fs.Condition.String())
+	}
+	if fs.Recursive {
+		out.WriteString(" recursive")
+	}
+	out.WriteString(";")
+	out.WriteString(statementsString(fs.Body))
+
+	if fs.Alternative != nil {
+		out.WriteString("else;")
+		out.WriteString(statementsString(fs.Alternative))
+	}
+
+	out.WriteString("endfor;")
+	return out.String()
+}
+
+type Parameter struct {
+	Name    *Identifier
+	Default Expression
+	Span
+}
+
+func (p *Parameter) String() string {
+	if p.Default == nil {
+		return p.Name.String()
+	}
+	return p.Name.String() + "=" + p.Default.String()
+}
+
+// MacroStatement is a {% macro %} block, dbt's {% test %} blocks share its shape
+type MacroStatement struct {
+	Keyword    string
+	Name       *Identifier
+	Parameters []*Parameter
+	Body       []Statement
+	Token      Token
+	Span
+}
+
+func (ms *MacroStatement) statementNode()       {}
+func (ms *MacroStatement) TokenLiteral() string { return ms.Token.Value }
+func (ms *MacroStatement) String() string {
+	return fmt.Sprintf("%s %s(%s);%send%s;", ms.Keyword, ms.Name.String(), parametersString(ms.Parameters), statementsString(ms.Body), ms.Keyword)
+}
+
+type CallBlockStatement struct {
+	Parameters []*Parameter
+	Call       *CallExpression
+	Body       []Statement
+	Token      Token
+	Span
+}
+
+func (cs *CallBlockStatement) statementNode()       {}
+func (cs *CallBlockStatement) TokenLiteral() string { return cs.Token.Value }
+func (cs *CallBlockStatement) String() string {
+	return fmt.Sprintf("call(%s) %s;%sendcall;", parametersString(cs.Parameters), cs.Call.String(), statementsString(cs.Body))
+}
+
+type FilterBlockStatement struct {
+	// Filter has no Value, the body is what gets filtered
+	Filter *FilterExpression
+	Body   []Statement
+	Token  Token
+	Span
+}
+
+func (fs *FilterBlockStatement) statementNode()       {}
+func (fs *FilterBlockStatement) TokenLiteral() string { return fs.Token.Value }
+func (fs *FilterBlockStatement) String() string {
+	return fmt.Sprintf("filter %s;%sendfilter;", fs.Filter.String(), statementsString(fs.Body))
+}
+
+// BlockStatement covers named blocks with a body: block, and dbt's docs and snapshot
+type BlockStatement struct {
+	Keyword string
+	Name    *Identifier
+	Body    []Statement
+	Token   Token
+	Span
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Value }
+func (bs *BlockStatement) String() string {
+	return fmt.Sprintf("%s %s;%send%s;", bs.Keyword, bs.Name.String(), statementsString(bs.Body), bs.Keyword)
+}
 
 // Expressions
 type Identifier struct {
 	Value string
 	Token Token
+	Span
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -89,8 +339,342 @@ func (i *Identifier) String() string       { return i.Value }
 type IntegerExpression struct {
 	Value int64
 	Token Token
+	Span
 }
 
 func (i *IntegerExpression) expressionNode()      {}
 func (i *IntegerExpression) TokenLiteral() string { return i.Token.Value }
 func (i *IntegerExpression) String() string       { return fmt.Sprintf("%v", i.Token.Value) }
+
+type FloatExpression struct {
+	Value float64
+	Token Token
+	Span
+}
+
+func (f *FloatExpression) expressionNode()      {}
+func (f *FloatExpression) TokenLiteral() string { return f.Token.Value }
+func (f *FloatExpression) String() string       { return f.Token.Value }
+
+type StringExpression struct {
+	// Value is the unquoted string
+	Value string
+	Token Token
+	Span
+}
+
+func (s *StringExpression) expressionNode()      {}
+func (s *StringExpression) TokenLiteral() string { return s.Token.Value }
+func (s *StringExpression) String() string       { return s.Token.Value }
+
+// ValueSpan is the span of the string without its quotes
+func (s *StringExpression) ValueSpan() Span {
+	raw := s.Token.Value
+	if len(raw) < 2 || raw[len(raw)-1] != raw[0] {
+		return Span{Start: min(s.Start+1, s.End), End: s.End}
+	}
+	return Span{Start: s.Start + 1, End: s.End - 1}
+}
+
+type BooleanExpression struct {
+	Value bool
+	Token Token
+	Span
+}
+
+func (b *BooleanExpression) expressionNode()      {}
+func (b *BooleanExpression) TokenLiteral() string { return b.Token.Value }
+func (b *BooleanExpression) String() string       { return b.Token.Value }
+
+type NoneExpression struct {
+	Token Token
+	Span
+}
+
+func (n *NoneExpression) expressionNode()      {}
+func (n *NoneExpression) TokenLiteral() string { return n.Token.Value }
+func (n *NoneExpression) String() string       { return n.Token.Value }
+
+type ListExpression struct {
+	Elements []Expression
+	Token    Token
+	Span
+}
+
+func (l *ListExpression) expressionNode()      {}
+func (l *ListExpression) TokenLiteral() string { return l.Token.Value }
+func (l *ListExpression) String() string       { return "[" + joinExpressions(l.Elements) + "]" }
+
+type TupleExpression struct {
+	Elements []Expression
+	Token    Token
+	Span
+}
+
+func (t *TupleExpression) expressionNode()      {}
+func (t *TupleExpression) TokenLiteral() string { return t.Token.Value }
+func (t *TupleExpression) String() string       { return "(" + joinExpressions(t.Elements) + ")" }
+
+type DictPair struct {
+	Key   Expression
+	Value Expression
+}
+
+type DictExpression struct {
+	Pairs []DictPair
+	Token Token
+	Span
+}
+
+func (d *DictExpression) expressionNode()      {}
+func (d *DictExpression) TokenLiteral() string { return d.Token.Value }
+func (d *DictExpression) String() string {
+	pairs := []string{}
+	for _, pair := range d.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+type PrefixExpression struct {
+	Operator string
+	Right    Expression
+	Token    Token
+	Span
+}
+
+func (pe *PrefixExpression) expressionNode()      {}
+func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Value }
+func (pe *PrefixExpression) String() string {
+	if pe.Operator == "not" {
+		return "(not " + pe.Right.String() + ")"
+	}
+	return "(" + pe.Operator + pe.Right.String() + ")"
+}
+
+type InfixExpression struct {
+	Left     Expression
+	Operator string
+	Right    Expression
+	Token    Token
+	Span
+}
+
+func (ie *InfixExpression) expressionNode()      {}
+func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Value }
+func (ie *InfixExpression) String() string {
+	return "(" + ie.Left.String() + " " + ie.Operator + " " + ie.Right.String() + ")"
+}
+
+// ConditionalExpression is the inline `a if b else c`
+type ConditionalExpression struct {
+	Consequence Expression
+	Condition   Expression
+	Alternative Expression
+	Token       Token
+	Span
+}
+
+func (ce *ConditionalExpression) expressionNode()      {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Value }
+func (ce *ConditionalExpression) String() string {
+	out := "(" + ce.Consequence.String() + " if " + ce.Condition.String()
+	if ce.Alternative != nil {
+		out += " else " + ce.Alternative.String()
+	}
+	return out + ")"
+}
+
+type AttributeExpression struct {
+	Object    Expression
+	Attribute *Identifier
+	Token     Token
+	Span
+}
+
+func (ae *AttributeExpression) expressionNode()      {}
+func (ae *AttributeExpression) TokenLiteral() string { return ae.Token.Value }
+func (ae *AttributeExpression) String() string {
+	return ae.Object.String() + "." + ae.Attribute.String()
+}
+
+type IndexExpression struct {
+	Object Expression
+	Index  Expression
+	Token  Token
+	Span
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Value }
+func (ie *IndexExpression) String() string {
+	return ie.Object.String() + "[" + ie.Index.String() + "]"
+}
+
+type SliceExpression struct {
+	Lower Expression
+	Upper Expression
+	Step  Expression
+	Token Token
+	Span
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Value }
+func (se *SliceExpression) String() string {
+	out := expressionString(se.Lower) + ":" + expressionString(se.Upper)
+	if se.Step != nil {
+		out += ":" + se.Step.String()
+	}
+	return out
+}
+
+type KeywordArgument struct {
+	Name  *Identifier
+	Value Expression
+	Span
+}
+
+func (ka *KeywordArgument) String() string {
+	return ka.Name.String() + "=" + ka.Value.String()
+}
+
+type CallExpression struct {
+	Function  Expression
+	Arguments []Expression
+	Keywords  []*KeywordArgument
+	Token     Token
+	Span
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Value }
+func (ce *CallExpression) String() string {
+	return ce.Function.String() + "(" + argumentsString(ce.Arguments, ce.Keywords) + ")"
+}
+
+// FunctionName returns the dotted name of the called function, e.g. dbt_utils.star
+func (ce *CallExpression) FunctionName() string {
+	return dottedName(ce.Function)
+}
+
+// Keyword returns the value passed for a keyword argument
+func (ce *CallExpression) Keyword(name string) (Expression, bool) {
+	for _, keyword := range ce.Keywords {
+		if keyword.Name.Value == name {
+			return keyword.Value, true
+		}
+	}
+	return nil, false
+}
+
+type FilterExpression struct {
+	Value     Expression
+	Name      *Identifier
+	Arguments []Expression
+	Keywords  []*KeywordArgument
+	Token     Token
+	Span
+}
+
+func (fe *FilterExpression) expressionNode()      {}
+func (fe *FilterExpression) TokenLiteral() string { return fe.Token.Value }
+func (fe *FilterExpression) String() string {
+	out := fe.Name.String()
+	if fe.Arguments != nil || fe.Keywords != nil {
+		out += "(" + argumentsString(fe.Arguments, fe.Keywords) + ")"
+	}
+
+	if fe.Value == nil {
+		return out
+	}
+	return "(" + fe.Value.String() + " | " + out + ")"
+}
+
+// TestExpression is `value is [not] test`
+type TestExpression struct {
+	Value     Expression
+	Name      *Identifier
+	Negated   bool
+	Arguments []Expression
+	Token     Token
+	Span
+}
+
+func (te *TestExpression) expressionNode()      {}
+func (te *TestExpression) TokenLiteral() string { return te.Token.Value }
+func (te *TestExpression) String() string {
+	out := "(" + te.Value.String() + " is "
+	if te.Negated {
+		out += "not "
+	}
+	out += te.Name.String()
+	if len(te.Arguments) > 0 {
+		out += "(" + joinExpressions(te.Arguments) + ")"
+	}
+	return out + ")"
+}
+
+func dottedName(expression Expression) string {
+	switch e := expression.(type) {
+	case *Identifier:
+		return e.Value
+	case *AttributeExpression:
+		object := dottedName(e.Object)
+		if object == "" {
+			return ""
+		}
+		return object + "." + e.Attribute.Value
+	}
+	return ""
+}
+
+func statementsString(statements []Statement) string {
+	var out bytes.Buffer
+	for _, s := range statements {
+		out.WriteString(s.String())
+	}
+	return out.String()
+}
+
+func expressionString(expression Expression) string {
+	if expression == nil {
+		return ""
+	}
+	return expression.String()
+}
+
+func joinExpressions(expressions []Expression) string {
+	values := []string{}
+	for _, e := range expressions {
+		values = append(values, e.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+func identifiersToExpressions(identifiers []*Identifier) []Expression {
+	expressions := []Expression{}
+	for _, i := range identifiers {
+		expressions = append(expressions, i)
+	}
+	return expressions
+}
+
+func parametersString(parameters []*Parameter) string {
+	values := []string{}
+	for _, p := range parameters {
+		values = append(values, p.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+func argumentsString(arguments []Expression, keywords []*KeywordArgument) string {
+	values := []string{}
+	for _, a := range arguments {
+		values = append(values, a.String())
+	}
+	for _, k := range keywords {
+		values = append(values, k.String())
+	}
+	return strings.Join(values, ", ")
+}
@@ -1,14 +1,24 @@
 package jinja
 
+import (
+	"regexp"
+	"strings"
+)
+
 type TokenType int
 
 type Token struct {
 	Value string
 	Token TokenType
+	// Start and End are byte offsets into the input
+	Start int
+	End   int
 }
 
 var eof = rune(0)
 
+var endRawPattern = regexp.MustCompile(`{%[-+]?\s*endraw\s*[-+]?%}`)
+
 var keywords = map[string]TokenType{
 	"set":       SET,
 	"endset":    END_SET,
@@ -19,6 +29,8 @@ var keywords = map[string]TokenType{
 	"endmacro":  END_MACRO,
 	"if":        IF,
 	"elif":      ELIF,
+	"else":      ELSE,
+	"endif":     END_IF,
 	"is":        IS,
 	"block":     BLOCK,
 	"endblock":  END_BLOCK,
@@ -29,6 +41,17 @@ var keywords = map[string]TokenType{
 	"filter":    FILTER,
 	"endfilter": END_FILTER,
 	"not":       NOT,
+	"and":       AND,
+	"or":        OR,
+	"do":        DO,
+	"raw":       RAW,
+	"endraw":    END_RAW,
+	"true":      TRUE,
+	"True":      TRUE,
+	"false":     FALSE,
+	"False":     FALSE,
+	"none":      NONE,
+	"None":      NONE,
 }
 
 const (
@@ -38,6 +61,8 @@ const (
 	PERCENT
 	IDENT
 	INT
+	FLOAT
+	STRING
 	PIPE
 	TILDA
 	LEFT_BRACKET
@@ -46,18 +71,21 @@ const (
 	PLUS
 	MINUS
 	SLASH
+	FLOOR_DIVIDE
 	ASTERIKS
+	POWER
 	COMMA
+	COLON
 	BANG
 	COLLECTION
 	SEMI_COLON
 	START_COLLECTION
 	END_COLLECTION
-	SINGLE_QUOTE
-	QUOTE
 	DOT
 	LT
 	GT
+	LT_EQ
+	GT_EQ
 	EQ
 	NOT_EQ
 
@@ -84,12 +112,22 @@ const (
 	END_MACRO
 	IF
 	ELIF
+	ELSE
+	END_IF
 	SCOPED
 	CALL
 	END_CALL
 	FILTER
 	END_FILTER
 	NOT
+	AND
+	OR
+	DO
+	RAW
+	END_RAW
+	TRUE
+	FALSE
+	NONE
 
 	// other stuff
 	ILLEGAL
@@ -101,11 +139,16 @@ const (
 )
 
 type Lexer struct {
-	input        string
-	position     int
-	readPosition int
-	ch           byte
-	withinJinja  bool
+	input         string
+	position      int
+	readPosition  int
+	ch            byte
+	withinJinja   bool
+	withinRaw     bool
+	withinComment bool
+
+	// previous tokens in the current statement, used to spot {% raw %}
+	statement []TokenType
 }
 
 func NewJinjaLexer(input string) *Lexer {
@@ -133,11 +176,20 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
+func (l *Lexer) peekCharAt(offset int) byte {
+	if l.position+offset >= len(l.input) {
+		return 0
+	}
+	return l.input[l.position+offset]
+}
+
 func (l *Lexer) NextToken() Token {
 	var tok Token
 
-	if l.ch == 0 {
-		return Token{Token: EOF, Value: ""}
+	if l.position >= len(l.input) {
+		return Token{Token: EOF, Value: "", Start: len(l.input), End: len(l.input)}
+	} else if l.withinComment {
+		return l.nextCommentToken()
 	} else if l.withinJinja {
 		return l.nextJinjaToken()
 	}
@@ -152,25 +204,68 @@ func (l *Lexer) NextToken() Token {
 		return peekChar == '{' || peekChar == '%' || peekChar == '#'
 	}
 
+	// everything up to {% endraw %} is text
+	if l.withinRaw {
+		l.withinRaw = false
+
+		end := len(l.input)
+		if index := endRawPattern.FindStringIndex(l.input[position:]); index != nil {
+			end = position + index[0]
+		}
+
+		if end > position {
+			l.readPosition = end
+			l.readChar()
+			return Token{Token: TEXT, Value: l.input[position:end], Start: position, End: end}
+		}
+	}
+
 	// are we starting out with a jinja block?
 	if isNextCharacterJinja(l.ch) {
 		l.withinJinja = true
 		return l.nextJinjaToken()
 	}
 
-	for !isNextCharacterJinja(l.ch) && l.ch != 0 {
+	for !isNextCharacterJinja(l.ch) && l.position < len(l.input) {
 		l.readChar()
 	}
 
 	tok.Value = l.input[position:l.position]
 	tok.Token = TEXT
+	tok.Start = position
+	tok.End = l.position
 	return tok
 }
 
+// nextCommentToken returns the body of a {# #} comment as a single text token
+func (l *Lexer) nextCommentToken() Token {
+	position := l.position
+
+	end := strings.Index(l.input[position:], "#}")
+	if end == -1 {
+		end = len(l.input)
+	} else {
+		end += position
+		if end > position && l.input[end-1] == '-' {
+			end -= 1
+		}
+	}
+
+	if end == position {
+		l.withinComment = false
+		return l.nextJinjaToken()
+	}
+
+	l.readPosition = end
+	l.readChar()
+	return Token{Token: TEXT, Value: l.input[position:end], Start: position, End: end}
+}
+
 func (l *Lexer) nextJinjaToken() Token {
 	var tok Token
 
 	l.skipWhitespace()
+	start := l.position
 
 	switch l.ch {
 
@@ -179,21 +274,20 @@ func (l *Lexer) nextJinjaToken() Token {
 
 		switch nextChar {
 		case '{':
-			ch := l.ch
 			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: START_EXPRESSION, Value: literal}
+			l.readWhitespaceControl()
+			tok = Token{Token: START_EXPRESSION, Value: l.input[start:l.readPosition]}
 
 		case '%':
-			ch := l.ch
 			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: START_STATEMENT, Value: literal}
+			l.readWhitespaceControl()
+			tok = Token{Token: START_STATEMENT, Value: l.input[start:l.readPosition]}
+			l.statement = []TokenType{}
 		case '#':
-			ch := l.ch
 			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: START_COMMENT, Value: literal}
+			l.readWhitespaceControl()
+			tok = Token{Token: START_COMMENT, Value: l.input[start:l.readPosition]}
+			l.withinComment = true
 		default:
 			tok = newToken(LEFT_BRACE, l.ch)
 		}
@@ -201,46 +295,49 @@ func (l *Lexer) nextJinjaToken() Token {
 	case '%':
 		nextChar := l.peekChar()
 		if nextChar == '}' {
-			ch := l.ch
-
-			l.readChar()
-			l.withinJinja = false
-
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: END_STATEMENT, Value: literal}
-
+			tok = l.endJinjaBlock(END_STATEMENT, start)
+			if len(l.statement) == 1 && l.statement[0] == RAW {
+				l.withinRaw = true
+			}
 		} else {
-			tok = newToken(ASSIGN, l.ch)
+			tok = newToken(PERCENT, l.ch)
 		}
 
 	case '#':
 		nextChar := l.peekChar()
 		if nextChar == '}' {
-			ch := l.ch
-
-			l.readChar()
-			l.withinJinja = false
-
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: END_COMMENT, Value: literal}
-
+			tok = l.endJinjaBlock(END_COMMENT, start)
 		} else {
-			tok = newToken(ASSIGN, l.ch)
+			tok = newToken(ILLEGAL, l.ch)
 		}
 
 	case '}':
 		nextChar := l.peekChar()
 		if nextChar == '}' {
-			ch := l.ch
+			tok = l.endJinjaBlock(END_EXPRESSION, start)
+		} else {
+			tok = newToken(RIGHT_BRACE, l.ch)
+		}
 
+	case '-', '+':
+		// whitespace control, e.g. -%} or -}}
+		if (l.peekChar() == '%' || l.peekChar() == '}' || l.peekChar() == '#') && l.peekCharAt(2) == '}' {
 			l.readChar()
-			l.withinJinja = false
-
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: END_EXPRESSION, Value: literal}
-
+			switch l.ch {
+			case '%':
+				tok = l.endJinjaBlock(END_STATEMENT, start)
+				if len(l.statement) == 1 && l.statement[0] == RAW {
+					l.withinRaw = true
+				}
+			case '#':
+				tok = l.endJinjaBlock(END_COMMENT, start)
+			default:
+				tok = l.endJinjaBlock(END_EXPRESSION, start)
+			}
+		} else if l.ch == '-' {
+			tok = newToken(MINUS, l.ch)
 		} else {
-			tok = newToken(RIGHT_BRACE, l.ch)
+			tok = newToken(PLUS, l.ch)
 		}
 
 	case '=':
@@ -248,57 +345,72 @@ func (l *Lexer) nextJinjaToken() Token {
 
 		switch nextChar {
 		case '=':
-			ch := l.ch
 			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: EQ, Value: literal}
-		case '!':
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: NOT_EQ, Value: literal}
+			tok = Token{Token: EQ, Value: "=="}
 		default:
-
 			tok = newToken(ASSIGN, l.ch)
 		}
 
+	case '*':
+		if l.peekChar() == '*' {
+			l.readChar()
+			tok = Token{Token: POWER, Value: "**"}
+		} else {
+			tok = newToken(ASTERIKS, l.ch)
+		}
+
+	case '/':
+		if l.peekChar() == '/' {
+			l.readChar()
+			tok = Token{Token: FLOOR_DIVIDE, Value: "//"}
+		} else {
+			tok = newToken(SLASH, l.ch)
+		}
+
 	case '(':
 		tok = newToken(LEFT_BRACKET, l.ch)
 	case ')':
 		tok = newToken(RIGHT_BRACKET, l.ch)
-	case '+':
-		tok = newToken(PLUS, l.ch)
-	case '-':
-		tok = newToken(MINUS, l.ch)
-	case '/':
-		tok = newToken(SLASH, l.ch)
 	case '!':
 		nextChar := l.peekChar()
 		if nextChar == '=' {
-			ch := l.ch
 			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = Token{Token: NOT_EQ, Value: literal}
+			tok = Token{Token: NOT_EQ, Value: "!="}
 		} else {
 			tok = newToken(BANG, l.ch)
 		}
 
 	case '<':
-		tok = newToken(LT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Token: LT_EQ, Value: "<="}
+		} else {
+			tok = newToken(LT, l.ch)
+		}
 	case '>':
-		tok = newToken(GT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Token: GT_EQ, Value: ">="}
+		} else {
+			tok = newToken(GT, l.ch)
+		}
 	case ',':
 		tok = newToken(COMMA, l.ch)
+	case ':':
+		tok = newToken(COLON, l.ch)
 	case ';':
 		tok = newToken(SEMI_COLON, l.ch)
 	case '[':
 		tok = newToken(START_COLLECTION, l.ch)
 	case ']':
 		tok = newToken(END_COLLECTION, l.ch)
-	case '"':
-		tok = newToken(QUOTE, l.ch)
-	case '\'':
-		tok = newToken(SINGLE_QUOTE, l.ch)
+	case '"', '\'':
+		tok.Value = l.readString(l.ch)
+		tok.Token = STRING
+		tok.Start = start
+		tok.End = l.position
+		l.statement = append(l.statement, tok.Token)
+		return tok
 	case '.':
 		tok = newToken(DOT, l.ch)
 	case '~':
@@ -308,25 +420,53 @@ func (l *Lexer) nextJinjaToken() Token {
 	case 0:
 		tok.Token = EOF
 		tok.Value = ""
+		tok.Start = start
+		tok.End = start
+		return tok
 
 	default:
 		if isLetter(l.ch) {
 			tok.Value = l.readIdentifier()
 			tok.Token = LookupIdent(tok.Value)
+			tok.Start = start
+			tok.End = l.position
+			l.statement = append(l.statement, tok.Token)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Value = l.readNumber()
-			tok.Token = INT
+			tok.Value, tok.Token = l.readNumber()
+			tok.Start = start
+			tok.End = l.position
+			l.statement = append(l.statement, tok.Token)
 			return tok
 		}
 		tok.Token = ILLEGAL
-		tok.Value = ""
+		tok.Value = string(l.ch)
 	}
 
 	l.readChar()
+	tok.Start = start
+	tok.End = l.position
+	if tok.Token != START_STATEMENT {
+		l.statement = append(l.statement, tok.Token)
+	}
 	return tok
 }
 
+// readWhitespaceControl consumes the - or + that can follow {{, {% or {#
+func (l *Lexer) readWhitespaceControl() {
+	if next := l.peekChar(); next == '-' || next == '+' {
+		l.readChar()
+	}
+}
+
+// endJinjaBlock reads the last character of a closing tag and leaves jinja mode
+func (l *Lexer) endJinjaBlock(tokenType TokenType, start int) Token {
+	l.readChar()
+	l.withinJinja = false
+	l.withinComment = false
+	return Token{Token: tokenType, Value: l.input[start:l.readPosition]}
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
@@ -342,18 +482,57 @@ func LookupIdent(ident string) TokenType {
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+func (l *Lexer) readNumber() (string, TokenType) {
 	position := l.position
-	for isDigit(l.ch) || l.ch == '_' {
+	tokenType := INT
+	for isDigit(l.ch) || l.ch == '_' || (l.ch == '.' && isDigit(l.peekChar()) && tokenType == INT) {
+		if l.ch == '.' {
+			tokenType = FLOAT
+		}
 		l.readChar()
 	}
-	return l.input[position:l.position]
+
+	// an exponent like 1.5e3 or 2E-4
+	if l.ch == 'e' || l.ch == 'E' {
+		offset := 1
+		if next := l.peekCharAt(1); next == '+' || next == '-' {
+			offset = 2
+		}
+		if isDigit(l.peekCharAt(offset)) {
+			tokenType = FLOAT
+			for range offset {
+				l.readChar()
+			}
+			for isDigit(l.ch) || l.ch == '_' {
+				l.readChar()
+			}
+		}
+	}
+	return l.input[position:l.position], tokenType
+}
+
+// readString reads a quoted string including its quotes. An unterminated
+// string runs to the end of the input
+func (l *Lexer) readString(quote byte) string {
+	position := l.position
+	l.readChar()
+	for l.position < len(l.input) && l.ch != quote {
+		if l.ch == '\\' {
+			l.readChar()
+		}
+		l.readChar()
+	}
+
+	if l.position < len(l.input) {
+		l.readChar()
+	}
+	return l.input[position:min(l.position, len(l.input))]
 }
 
 func isLetter(ch byte) bool {
@@ -56,9 +56,7 @@ func Test_Identifiers(t *testing.T) {
 		{Token: IDENT, Value: "result"},
 		{Token: ASSIGN, Value: "="},
 		{Token: START_COLLECTION, Value: "["},
-		{Token: QUOTE, Value: "\""},
-		{Token: IDENT, Value: "thing"},
-		{Token: QUOTE, Value: "\""},
+		{Token: STRING, Value: "\"thing\""},
 		{Token: END_COLLECTION, Value: "]"},
 		{Token: END_EXPRESSION, Value: "}}"},
 		{Token: EOF, Value: ""},
@@ -66,6 +64,80 @@ func Test_Identifiers(t *testing.T) {
 	runTests(input, tests, t)
 }
 
+func Test_Operators(t *testing.T) {
+	input := "{{ a ** 2 // 3 % 4 <= 5 >= 6 != 7 == 8.5 ~ 'x' }}"
+	tests := []Token{
+		{Token: START_EXPRESSION, Value: "{{"},
+		{Token: IDENT, Value: "a"},
+		{Token: POWER, Value: "**"},
+		{Token: INT, Value: "2"},
+		{Token: FLOOR_DIVIDE, Value: "//"},
+		{Token: INT, Value: "3"},
+		{Token: PERCENT, Value: "%"},
+		{Token: INT, Value: "4"},
+		{Token: LT_EQ, Value: "<="},
+		{Token: INT, Value: "5"},
+		{Token: GT_EQ, Value: ">="},
+		{Token: INT, Value: "6"},
+		{Token: NOT_EQ, Value: "!="},
+		{Token: INT, Value: "7"},
+		{Token: EQ, Value: "=="},
+		{Token: FLOAT, Value: "8.5"},
+		{Token: TILDA, Value: "~"},
+		{Token: STRING, Value: "'x'"},
+		{Token: END_EXPRESSION, Value: "}}"},
+		{Token: EOF, Value: ""},
+	}
+	runTests(input, tests, t)
+}
+
+func Test_WhitespaceControl(t *testing.T) {
+	input := "{%- if a -%}x{{- b -}}"
+	tests := []Token{
+		{Token: START_STATEMENT, Value: "{%-"},
+		{Token: IF, Value: "if"},
+		{Token: IDENT, Value: "a"},
+		{Token: END_STATEMENT, Value: "-%}"},
+		{Token: TEXT, Value: "x"},
+		{Token: START_EXPRESSION, Value: "{{-"},
+		{Token: IDENT, Value: "b"},
+		{Token: END_EXPRESSION, Value: "-}}"},
+		{Token: EOF, Value: ""},
+	}
+	runTests(input, tests, t)
+}
+
+func Test_CommentAndRaw(t *testing.T) {
+	input := "{# a {{ comment }} #}{% raw %}{{ not jinja }}{% endraw %}"
+	tests := []Token{
+		{Token: START_COMMENT, Value: "{#"},
+		{Token: TEXT, Value: " a {{ comment }} "},
+		{Token: END_COMMENT, Value: "#}"},
+		{Token: START_STATEMENT, Value: "{%"},
+		{Token: RAW, Value: "raw"},
+		{Token: END_STATEMENT, Value: "%}"},
+		{Token: TEXT, Value: "{{ not jinja }}"},
+		{Token: START_STATEMENT, Value: "{%"},
+		{Token: END_RAW, Value: "endraw"},
+		{Token: END_STATEMENT, Value: "%}"},
+		{Token: EOF, Value: ""},
+	}
+	runTests(input, tests, t)
+}
+
+func Test_TokenPositions(t *testing.T) {
+	input := "select {{ ref('orders') }}"
+	expected := [][]int{{0, 7}, {7, 9}, {10, 13}, {13, 14}, {14, 22}, {22, 23}, {24, 26}, {26, 26}}
+
+	lexer := NewJinjaLexer(input)
+	for i, positions := range expected {
+		tok := lexer.NextToken()
+		if tok.Start != positions[0] || tok.End != positions[1] {
+			t.Fatalf("test[%d] - %v expected %v, got=%v %v", i, tok.Value, positions, tok.Start, tok.End)
+		}
+	}
+}
+
 func runTests(input string, tokens []Token, t *testing.T) {
 	lexer := NewJinjaLexer(input)
 	for i, tt := range tokens {
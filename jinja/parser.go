@@ -2,20 +2,56 @@ package jinja
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
-	EQUALS    // ==
-	LESSGREAT // > or <
-	SUM       // +
-	PRODUCT   // *
-	PREFIX    // -x or +x
-	FUNCTION  // myFunction(x)
+	CONDITIONAL // x if y else z
+	LOGICAL_OR  // or
+	LOGICAL_AND // and
+	NEGATE      // not x
+	EQUALS      // ==, !=, in, is
+	LESSGREAT   // > or <
+	SUM         // +
+	CONCAT      // ~
+	PRODUCT     // *
+	EXPONENT    // **
+	PREFIX      // -x or +x
+	PIPELINE    // x | filter
+	FUNCTION    // myFunction(x), x.y, x[y]
 )
 
+var precedences = map[TokenType]int{
+	IF:               CONDITIONAL,
+	OR:               LOGICAL_OR,
+	AND:              LOGICAL_AND,
+	EQ:               EQUALS,
+	NOT_EQ:           EQUALS,
+	IN:               EQUALS,
+	NOT:              EQUALS,
+	IS:               EQUALS,
+	LT:               LESSGREAT,
+	GT:               LESSGREAT,
+	LT_EQ:            LESSGREAT,
+	GT_EQ:            LESSGREAT,
+	PLUS:             SUM,
+	MINUS:            SUM,
+	TILDA:            CONCAT,
+	ASTERIKS:         PRODUCT,
+	SLASH:            PRODUCT,
+	FLOOR_DIVIDE:     PRODUCT,
+	PERCENT:          PRODUCT,
+	POWER:            EXPONENT,
+	PIPE:             PIPELINE,
+	LEFT_BRACKET:     FUNCTION,
+	DOT:              FUNCTION,
+	START_COLLECTION: FUNCTION,
+}
+
 type Parser struct {
 	l         *Lexer
 	curToken  Token
@@ -23,7 +59,7 @@ type Parser struct {
 	errors    []Error
 
 	prefixParseFns map[TokenType]prefixParseFn
-	infixParseFn   map[TokenType]infixParseFn
+	infixParseFns  map[TokenType]infixParseFn
 }
 
 type Error struct {
@@ -44,6 +80,30 @@ func NewParser(l *Lexer) *Parser {
 
 	p.prefixParseFns = make(map[TokenType]prefixParseFn)
 	p.registerPrefix(IDENT, p.parseIdentifier)
+	p.registerPrefix(INT, p.parseIntegerLiteral)
+	p.registerPrefix(FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(STRING, p.parseStringLiteral)
+	p.registerPrefix(TRUE, p.parseBooleanLiteral)
+	p.registerPrefix(FALSE, p.parseBooleanLiteral)
+	p.registerPrefix(NONE, p.parseNoneLiteral)
+	p.registerPrefix(MINUS, p.parsePrefixExpression)
+	p.registerPrefix(PLUS, p.parsePrefixExpression)
+	p.registerPrefix(NOT, p.parsePrefixExpression)
+	p.registerPrefix(LEFT_BRACKET, p.parseGroupedExpression)
+	p.registerPrefix(START_COLLECTION, p.parseListLiteral)
+	p.registerPrefix(LEFT_BRACE, p.parseDictLiteral)
+
+	p.infixParseFns = make(map[TokenType]infixParseFn)
+	for _, token := range []TokenType{PLUS, MINUS, ASTERIKS, SLASH, FLOOR_DIVIDE, PERCENT, POWER, TILDA, EQ, NOT_EQ, LT, GT, LT_EQ, GT_EQ, AND, OR, IN} {
+		p.registerInfix(token, p.parseInfixExpression)
+	}
+	p.registerInfix(NOT, p.parseNotInExpression)
+	p.registerInfix(IS, p.parseTestExpression)
+	p.registerInfix(PIPE, p.parseFilterExpression)
+	p.registerInfix(LEFT_BRACKET, p.parseCallExpression)
+	p.registerInfix(DOT, p.parseAttributeExpression)
+	p.registerInfix(START_COLLECTION, p.parseIndexExpression)
+	p.registerInfix(IF, p.parseConditionalExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -51,12 +111,19 @@ func NewParser(l *Lexer) *Parser {
 	return p
 }
 
+// ParseTemplate parses the whole input and returns the file with any errors found
+func ParseTemplate(input string) (*File, []Error) {
+	parser := NewParser(NewJinjaLexer(input))
+	file := parser.Parse()
+	return file, parser.GetErrors()
+}
+
 func (p *Parser) registerPrefix(token TokenType, fn prefixParseFn) {
 	p.prefixParseFns[token] = fn
 }
 
 func (p *Parser) registerInfix(token TokenType, fn infixParseFn) {
-	p.infixParseFn[token] = fn
+	p.infixParseFns[token] = fn
 }
 
 func (p *Parser) nextToken() {
@@ -82,17 +149,47 @@ func (p *Parser) expectPeek(t TokenType) bool {
 	return false
 }
 
+func (p *Parser) peekPrecedence() int {
+	if precedence, ok := precedences[p.peekToken.Token]; ok {
+		return precedence
+	}
+	return LOWEST
+}
+
+func (p *Parser) curPrecedence() int {
+	if precedence, ok := precedences[p.curToken.Token]; ok {
+		return precedence
+	}
+	return LOWEST
+}
+
 func (p *Parser) peekError(t TokenType) {
 	msg := fmt.Sprintf("expected next token to be %v, got %v instead", t, p.peekToken.Token)
-	p.errors = append(p.errors, Error{Value: msg, Position: 0})
+	p.errors = append(p.errors, Error{Value: msg, Position: p.peekToken.Start})
+}
+
+func (p *Parser) addError(position int, format string, args ...any) {
+	p.errors = append(p.errors, Error{Value: fmt.Sprintf(format, args...), Position: position})
 }
 
 func (p *Parser) GetErrors() []Error {
 	return p.errors
 }
 
+// skipTo moves forward to the closing token of the current block so one broken
+// tag doesn't take the rest of the file down with it
+func (p *Parser) skipTo(t TokenType) {
+	for !p.currentTokenIs(t) && !p.currentTokenIs(EOF) {
+		switch p.peekToken.Token {
+		case TEXT, START_EXPRESSION, START_STATEMENT, START_COMMENT:
+			return
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) Parse() *File {
-	file := &File{}
+	file := &File{Span: Span{Start: 0, End: len(p.l.input)}}
 	file.Statements = []Statement{}
 
 	for p.curToken.Token != EOF {
@@ -108,25 +205,155 @@ func (p *Parser) Parse() *File {
 
 func (p *Parser) parseStatement() Statement {
 	switch p.curToken.Token {
+	case TEXT:
+		return &TextStatement{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
+	case START_COMMENT:
+		return p.parseCommentStatement()
+	case START_EXPRESSION:
+		return p.parseExpressionStatement()
 	case START_STATEMENT:
+		start := p.curToken.Start
 		p.nextToken()
+
+		var stmt Statement
 		switch p.curToken.Token {
 		case SET:
-			return p.parseSetStatement()
+			stmt = p.parseSetStatement(start)
+		case IF:
+			stmt = p.parseIfStatement(start)
+		case FOR:
+			stmt = p.parseForStatement(start)
+		case MACRO:
+			stmt = p.parseMacroStatement(start)
+		case CALL:
+			stmt = p.parseCallBlockStatement(start)
+		case FILTER:
+			stmt = p.parseFilterBlockStatement(start)
+		case RAW:
+			stmt = p.parseRawStatement(start)
+		case DO:
+			stmt = p.parseDoStatement(start)
+		case BLOCK:
+			stmt = p.parseBlockStatement(start)
+		case IDENT:
+			switch p.curToken.Value {
+			case "test":
+				stmt = p.parseMacroStatement(start)
+			case "docs", "snapshot":
+				stmt = p.parseBlockStatement(start)
+			}
+		}
+
+		if stmt == nil {
+			if len(p.errors) == 0 || p.errors[len(p.errors)-1].Position < start {
+				p.addError(p.curToken.Start, "unknown tag %v", p.curToken.Value)
+			}
+			p.skipTo(END_STATEMENT)
 		}
+		return stmt
 	}
 
 	return nil
 }
 
-func (p *Parser) parseSetStatement() Statement {
-	stmt := &SetStatment{Token: p.curToken}
+func (p *Parser) curSpan() Span {
+	return Span{Start: p.curToken.Start, End: p.curToken.End}
+}
+
+// parseBody parses statements until it finds a tag starting with one of the
+// terminators. It leaves the current token on the terminator and returns where
+// the terminating tag starts
+func (p *Parser) parseBody(terminators ...string) ([]Statement, int, bool) {
+	body := []Statement{}
+	for !p.currentTokenIs(EOF) {
+		if p.currentTokenIs(START_STATEMENT) && slices.Contains(terminators, p.peekToken.Value) {
+			start := p.curToken.Start
+			p.nextToken()
+			return body, start, true
+		}
+
+		stmt := p.parseStatement()
+		if stmt != nil {
+			body = append(body, stmt)
+		}
+		p.nextToken()
+	}
+
+	p.addError(p.curToken.Start, "unexpected end of file, expected one of %v", strings.Join(terminators, ", "))
+	return body, p.curToken.Start, false
+}
+
+func (p *Parser) parseCommentStatement() *CommentStatement {
+	stmt := &CommentStatement{Token: p.curToken, Span: p.curSpan()}
+
+	if p.peekTokenIs(TEXT) {
+		p.nextToken()
+		stmt.Value = p.curToken.Value
+	}
+
+	if p.expectPeek(END_COMMENT) {
+		stmt.End = p.curToken.End
+	}
+	return stmt
+}
+
+func (p *Parser) parseExpressionStatement() Statement {
+	stmt := &ExpressionStatement{Token: p.curToken, Span: p.curSpan()}
+
+	p.nextToken()
+	stmt.Value = p.parseTupleExpression(LOWEST)
+	if stmt.Value == nil || !p.expectPeek(END_EXPRESSION) {
+		p.skipTo(END_EXPRESSION)
+		if stmt.Value == nil {
+			return nil
+		}
+	}
+
+	stmt.End = p.curToken.End
+	return stmt
+}
+
+func (p *Parser) parseSetStatement(start int) Statement {
+	stmt := &SetStatment{Token: p.curToken, Span: Span{Start: start}}
 
 	if !p.expectPeek(IDENT) {
 		return nil
 	}
 
-	stmt.Name = &Identifier{Token: p.curToken, Value: p.curToken.Value}
+	stmt.Name = &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
+
+	if p.peekTokenIs(DOT) {
+		p.nextToken()
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		stmt.Attribute = &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
+	} else if p.peekTokenIs(COMMA) {
+		// {% set a, b = 1, 2 %}
+		stmt.Names = []*Identifier{stmt.Name}
+		for p.peekTokenIs(COMMA) {
+			p.nextToken()
+			if !p.expectPeek(IDENT) {
+				return nil
+			}
+			stmt.Names = append(stmt.Names, &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()})
+		}
+	}
+
+	// {% set name %}...{% endset %}
+	if p.peekTokenIs(END_STATEMENT) && stmt.Names == nil {
+		p.nextToken()
+		p.nextToken()
+
+		body, _, ok := p.parseBody("endset")
+		stmt.Body = body
+		if ok && p.expectPeek(END_STATEMENT) {
+			stmt.End = p.curToken.End
+		} else {
+			stmt.End = p.curToken.Start
+		}
+		return stmt
+	}
 
 	if !p.expectPeek(ASSIGN) {
 		return nil
@@ -134,22 +361,837 @@ func (p *Parser) parseSetStatement() Statement {
 
 	p.nextToken()
 
+	stmt.Value = p.parseTupleExpression(LOWEST)
+	if stmt.Value == nil || !p.expectPeek(END_STATEMENT) {
+		return nil
+	}
+
+	stmt.End = p.curToken.End
+	return stmt
+}
+
+func (p *Parser) parseIfStatement(start int) Statement {
+	stmt := &IfStatement{Token: p.curToken, Span: Span{Start: start}}
+	branch := &IfBranch{Span: Span{Start: start}}
+
+	for {
+		p.nextToken()
+		branch.Condition = p.parseExpression(LOWEST)
+		if branch.Condition == nil || !p.expectPeek(END_STATEMENT) {
+			return nil
+		}
+		p.nextToken()
+
+		body, end, ok := p.parseBody("elif", "else", "endif")
+		branch.Consequence = body
+		branch.End = end
+		stmt.Branches = append(stmt.Branches, branch)
+
+		if !ok {
+			stmt.End = end
+			return stmt
+		}
+
+		if !p.currentTokenIs(ELIF) {
+			break
+		}
+		branch = &IfBranch{Span: Span{Start: end}}
+	}
+
+	if p.currentTokenIs(ELSE) {
+		if !p.expectPeek(END_STATEMENT) {
+			return nil
+		}
+		p.nextToken()
+
+		body, end, ok := p.parseBody("endif")
+		stmt.Alternative = body
+		if !ok {
+			stmt.End = end
+			return stmt
+		}
+	}
+
+	if p.expectPeek(END_STATEMENT) {
+		stmt.End = p.curToken.End
+	}
+	return stmt
+}
+
+func (p *Parser) parseForStatement(start int) Statement {
+	stmt := &ForStatement{Token: p.curToken, Span: Span{Start: start}}
+
+	for {
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		stmt.Targets = append(stmt.Targets, &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()})
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(IN) {
+		return nil
+	}
+	p.nextToken()
+
+	// parsing above CONDITIONAL leaves the loop filter's `if` alone
+	stmt.Iterable = p.parseTupleExpression(CONDITIONAL)
+	if stmt.Iterable == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(IF) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Condition = p.parseExpression(CONDITIONAL)
+		if stmt.Condition == nil {
+			return nil
+		}
+	}
+
+	if p.peekTokenIs(IDENT) && p.peekToken.Value == "recursive" {
+		p.nextToken()
+		stmt.Recursive = true
+	}
+
+	if !p.expectPeek(END_STATEMENT) {
+		return nil
+	}
+	p.nextToken()
+
+	body, end, ok := p.parseBody("else", "endfor")
+	stmt.Body = body
+	if !ok {
+		stmt.End = end
+		return stmt
+	}
+
+	if p.currentTokenIs(ELSE) {
+		if !p.expectPeek(END_STATEMENT) {
+			return nil
+		}
+		p.nextToken()
+
+		body, end, ok := p.parseBody("endfor")
+		stmt.Alternative = body
+		if !ok {
+			stmt.End = end
+			return stmt
+		}
+	}
+
+	if p.expectPeek(END_STATEMENT) {
+		stmt.End = p.curToken.End
+	}
+	return stmt
+}
+
+func (p *Parser) parseMacroStatement(start int) Statement {
+	stmt := &MacroStatement{Token: p.curToken, Keyword: p.curToken.Value, Span: Span{Start: start}}
+
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Name = &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
+
+	if !p.expectPeek(LEFT_BRACKET) {
+		return nil
+	}
+
+	parameters, ok := p.parseParameters()
+	if !ok {
+		return nil
+	}
+	stmt.Parameters = parameters
+
+	if !p.expectPeek(END_STATEMENT) {
+		return nil
+	}
+	p.nextToken()
+
+	body, end, ok := p.parseBody("end" + stmt.Keyword)
+	stmt.Body = body
+	if ok && p.expectPeek(END_STATEMENT) {
+		stmt.End = p.curToken.End
+	} else {
+		stmt.End = end
+	}
+	return stmt
+}
+
+// parseParameters parses a macro signature, the current token is the opening bracket
+func (p *Parser) parseParameters() ([]*Parameter, bool) {
+	parameters := []*Parameter{}
+
+	for {
+		if p.peekTokenIs(RIGHT_BRACKET) {
+			p.nextToken()
+			return parameters, true
+		}
+
+		if !p.expectPeek(IDENT) {
+			return nil, false
+		}
+
+		parameter := &Parameter{
+			Name: &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()},
+			Span: p.curSpan(),
+		}
+
+		if p.peekTokenIs(ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+
+			parameter.Default = p.parseExpression(LOWEST)
+			if parameter.Default == nil {
+				return nil, false
+			}
+			parameter.End = parameter.Default.GetSpan().End
+		}
+		parameters = append(parameters, parameter)
+
+		if p.peekTokenIs(COMMA) {
+			p.nextToken()
+			continue
+		}
+
+		if !p.expectPeek(RIGHT_BRACKET) {
+			return nil, false
+		}
+		return parameters, true
+	}
+}
+
+func (p *Parser) parseCallBlockStatement(start int) Statement {
+	stmt := &CallBlockStatement{Token: p.curToken, Span: Span{Start: start}, Parameters: []*Parameter{}}
+
+	if p.peekTokenIs(LEFT_BRACKET) {
+		p.nextToken()
+
+		parameters, ok := p.parseParameters()
+		if !ok {
+			return nil
+		}
+		stmt.Parameters = parameters
+	}
+
+	p.nextToken()
+	call, ok := p.parseExpression(LOWEST).(*CallExpression)
+	if !ok {
+		p.addError(p.curToken.Start, "expected a macro call in call block")
+		return nil
+	}
+	stmt.Call = call
+
+	if !p.expectPeek(END_STATEMENT) {
+		return nil
+	}
+	p.nextToken()
+
+	body, end, ok := p.parseBody("endcall")
+	stmt.Body = body
+	if ok && p.expectPeek(END_STATEMENT) {
+		stmt.End = p.curToken.End
+	} else {
+		stmt.End = end
+	}
+	return stmt
+}
+
+func (p *Parser) parseFilterBlockStatement(start int) Statement {
+	stmt := &FilterBlockStatement{Token: p.curToken, Span: Span{Start: start}}
+
+	filter, ok := p.parseFilterExpression(nil).(*FilterExpression)
+	if !ok {
+		return nil
+	}
+
+	for p.peekTokenIs(PIPE) {
+		p.nextToken()
+		filter, ok = p.parseFilterExpression(filter).(*FilterExpression)
+		if !ok {
+			return nil
+		}
+	}
+	stmt.Filter = filter
+
+	if !p.expectPeek(END_STATEMENT) {
+		return nil
+	}
+	p.nextToken()
+
+	body, end, ok := p.parseBody("endfilter")
+	stmt.Body = body
+	if ok && p.expectPeek(END_STATEMENT) {
+		stmt.End = p.curToken.End
+	} else {
+		stmt.End = end
+	}
+	return stmt
+}
+
+func (p *Parser) parseRawStatement(start int) Statement {
+	stmt := &RawStatement{Token: p.curToken, Span: Span{Start: start}}
+
+	if !p.expectPeek(END_STATEMENT) {
+		return nil
+	}
+
+	if p.peekTokenIs(TEXT) {
+		p.nextToken()
+		stmt.Value = p.curToken.Value
+	}
+
+	if !p.expectPeek(START_STATEMENT) || !p.expectPeek(END_RAW) || !p.expectPeek(END_STATEMENT) {
+		stmt.End = p.curToken.End
+		return stmt
+	}
+
+	stmt.End = p.curToken.End
+	return stmt
+}
+
+func (p *Parser) parseDoStatement(start int) Statement {
+	stmt := &DoStatement{Token: p.curToken, Span: Span{Start: start}}
+
+	p.nextToken()
+	stmt.Value = p.parseTupleExpression(LOWEST)
+	if stmt.Value == nil || !p.expectPeek(END_STATEMENT) {
+		return nil
+	}
+
+	stmt.End = p.curToken.End
 	return stmt
 }
 
+func (p *Parser) parseBlockStatement(start int) Statement {
+	stmt := &BlockStatement{Token: p.curToken, Keyword: p.curToken.Value, Span: Span{Start: start}}
+
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Name = &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
+
+	for p.peekTokenIs(SCOPED) || (p.peekTokenIs(IDENT) && p.peekToken.Value == "required") {
+		p.nextToken()
+	}
+
+	if !p.expectPeek(END_STATEMENT) {
+		return nil
+	}
+	p.nextToken()
+
+	body, end, ok := p.parseBody("end" + stmt.Keyword)
+	stmt.Body = body
+	if !ok {
+		stmt.End = end
+		return stmt
+	}
+
+	// {% endblock name %}
+	if p.peekTokenIs(IDENT) {
+		p.nextToken()
+	}
+
+	if p.expectPeek(END_STATEMENT) {
+		stmt.End = p.curToken.End
+	}
+	return stmt
+}
+
+func (p *Parser) parseExpression(precedence int) Expression {
+	prefix := p.prefixParseFns[p.curToken.Token]
+	if prefix == nil {
+		p.addError(p.curToken.Start, "no prefix parse function for %v found", p.curToken.Value)
+		return nil
+	}
+
+	leftExp := prefix()
+	for leftExp != nil && !p.peekTokenIs(END_EXPRESSION) && !p.peekTokenIs(END_STATEMENT) && precedence < p.peekPrecedence() {
+		infix := p.infixParseFns[p.peekToken.Token]
+		if infix == nil {
+			return leftExp
+		}
+
+		p.nextToken()
+		leftExp = infix(leftExp)
+	}
+
+	return leftExp
+}
+
+// parseTupleExpression parses an expression that may be an unbracketed tuple, `a, b`
+func (p *Parser) parseTupleExpression(precedence int) Expression {
+	first := p.parseExpression(precedence)
+	if first == nil || !p.peekTokenIs(COMMA) {
+		return first
+	}
+
+	tuple := &TupleExpression{Token: p.curToken, Elements: []Expression{first}, Span: first.GetSpan()}
+	for p.peekTokenIs(COMMA) {
+		p.nextToken()
+		if _, ok := p.prefixParseFns[p.peekToken.Token]; !ok {
+			break
+		}
+		p.nextToken()
+
+		element := p.parseExpression(precedence)
+		if element == nil {
+			return nil
+		}
+		tuple.Elements = append(tuple.Elements, element)
+	}
+
+	tuple.End = p.curToken.End
+	return tuple
+}
+
 func (p *Parser) parseIdentifier() Expression {
-	return &Identifier{Token: p.curToken, Value: p.curToken.Value}
+	return &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
 }
 
 func (p *Parser) parseIntegerLiteral() Expression {
-	lit := &IntegerExpression{Token: p.curToken}
+	lit := &IntegerExpression{Token: p.curToken, Span: p.curSpan()}
 
-	value, err := strconv.ParseInt(p.curToken.Value, 0, 64)
+	value, err := strconv.ParseInt(strings.ReplaceAll(p.curToken.Value, "_", ""), 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %v as integer", p.curToken.Value)
-		p.errors = append(p.errors, Error{Value: msg, Position: p.l.position})
+		p.errors = append(p.errors, Error{Value: msg, Position: p.curToken.Start})
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() Expression {
+	lit := &FloatExpression{Token: p.curToken, Span: p.curSpan()}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.curToken.Value, "_", ""), 64)
+	if err != nil {
+		p.addError(p.curToken.Start, "could not parse %v as float", p.curToken.Value)
 	}
 
 	lit.Value = value
 	return lit
 }
+
+// parseStringLiteral parses a string, adjacent strings like 'a' 'b' are joined
+// into one the way python does
+func (p *Parser) parseStringLiteral() Expression {
+	lit := &StringExpression{Token: p.curToken, Span: p.curSpan()}
+	lit.Value = p.parseStringValue()
+
+	for p.peekTokenIs(STRING) {
+		p.nextToken()
+		lit.Value += p.parseStringValue()
+		lit.Token.Value += " " + p.curToken.Value
+		lit.End = p.curToken.End
+	}
+	return lit
+}
+
+// parseStringValue unquotes the current string token
+func (p *Parser) parseStringValue() string {
+	raw := p.curToken.Value
+	if len(raw) < 2 || raw[len(raw)-1] != raw[0] {
+		p.addError(p.curToken.Start, "unterminated string %v", raw)
+		return unescapeString(raw[1:])
+	}
+	return unescapeString(raw[1 : len(raw)-1])
+}
+
+func unescapeString(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	replacer := strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`, `\n`, "\n", `\t`, "\t", `\r`, "\r")
+	return replacer.Replace(value)
+}
+
+func (p *Parser) parseBooleanLiteral() Expression {
+	return &BooleanExpression{Token: p.curToken, Value: p.currentTokenIs(TRUE), Span: p.curSpan()}
+}
+
+func (p *Parser) parseNoneLiteral() Expression {
+	return &NoneExpression{Token: p.curToken, Span: p.curSpan()}
+}
+
+func (p *Parser) parsePrefixExpression() Expression {
+	expression := &PrefixExpression{Token: p.curToken, Operator: p.curToken.Value, Span: p.curSpan()}
+
+	precedence := PREFIX
+	if p.currentTokenIs(NOT) {
+		precedence = NEGATE
+	}
+
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+	if expression.Right == nil {
+		return nil
+	}
+
+	expression.End = expression.Right.GetSpan().End
+	return expression
+}
+
+func (p *Parser) parseGroupedExpression() Expression {
+	start := p.curToken
+
+	if p.peekTokenIs(RIGHT_BRACKET) {
+		p.nextToken()
+		return &TupleExpression{Token: start, Elements: []Expression{}, Span: Span{Start: start.Start, End: p.curToken.End}}
+	}
+
+	p.nextToken()
+	expression := p.parseTupleExpression(LOWEST)
+	if expression == nil || !p.expectPeek(RIGHT_BRACKET) {
+		return nil
+	}
+
+	if tuple, ok := expression.(*TupleExpression); ok {
+		tuple.Token = start
+		tuple.Span = Span{Start: start.Start, End: p.curToken.End}
+	}
+	return expression
+}
+
+func (p *Parser) parseListLiteral() Expression {
+	list := &ListExpression{Token: p.curToken, Span: p.curSpan()}
+
+	elements, ok := p.parseExpressionList(END_COLLECTION)
+	if !ok {
+		return nil
+	}
+
+	list.Elements = elements
+	list.End = p.curToken.End
+	return list
+}
+
+// parseExpressionList parses comma separated expressions up to the end token,
+// a trailing comma is allowed
+func (p *Parser) parseExpressionList(end TokenType) ([]Expression, bool) {
+	elements := []Expression{}
+
+	for !p.peekTokenIs(end) {
+		p.nextToken()
+
+		element := p.parseExpression(LOWEST)
+		if element == nil {
+			return nil, false
+		}
+		elements = append(elements, element)
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(end) {
+		return nil, false
+	}
+	return elements, true
+}
+
+func (p *Parser) parseDictLiteral() Expression {
+	dict := &DictExpression{Token: p.curToken, Span: p.curSpan(), Pairs: []DictPair{}}
+
+	for !p.peekTokenIs(RIGHT_BRACE) {
+		p.nextToken()
+
+		key := p.parseExpression(LOWEST)
+		if key == nil || !p.expectPeek(COLON) {
+			return nil
+		}
+		p.nextToken()
+
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		dict.Pairs = append(dict.Pairs, DictPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(RIGHT_BRACE) {
+		return nil
+	}
+
+	dict.End = p.curToken.End
+	return dict
+}
+
+func (p *Parser) parseInfixExpression(left Expression) Expression {
+	expression := &InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Value,
+		Left:     left,
+		Span:     Span{Start: left.GetSpan().Start},
+	}
+
+	precedence := p.curPrecedence()
+	// ** is right associative
+	if p.currentTokenIs(POWER) {
+		precedence -= 1
+	}
+
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+	if expression.Right == nil {
+		return nil
+	}
+
+	expression.End = expression.Right.GetSpan().End
+	return expression
+}
+
+func (p *Parser) parseNotInExpression(left Expression) Expression {
+	expression := &InfixExpression{
+		Token:    p.curToken,
+		Operator: "not in",
+		Left:     left,
+		Span:     Span{Start: left.GetSpan().Start},
+	}
+
+	if !p.expectPeek(IN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Right = p.parseExpression(EQUALS)
+	if expression.Right == nil {
+		return nil
+	}
+
+	expression.End = expression.Right.GetSpan().End
+	return expression
+}
+
+func (p *Parser) parseTestExpression(left Expression) Expression {
+	expression := &TestExpression{Token: p.curToken, Value: left, Span: Span{Start: left.GetSpan().Start}}
+
+	if p.peekTokenIs(NOT) {
+		p.nextToken()
+		expression.Negated = true
+	}
+
+	switch p.peekToken.Token {
+	case IDENT, NONE, TRUE, FALSE, IN:
+		p.nextToken()
+	default:
+		p.peekError(IDENT)
+		return nil
+	}
+	expression.Name = &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
+
+	switch p.peekToken.Token {
+	case LEFT_BRACKET:
+		p.nextToken()
+		arguments, ok := p.parseExpressionList(RIGHT_BRACKET)
+		if !ok {
+			return nil
+		}
+		expression.Arguments = arguments
+
+	// tests can take a single argument without brackets, `x is divisibleby 3`
+	case IDENT, INT, FLOAT, STRING, TRUE, FALSE, NONE:
+		p.nextToken()
+		argument := p.parseExpression(PIPELINE)
+		if argument == nil {
+			return nil
+		}
+		expression.Arguments = []Expression{argument}
+	}
+
+	expression.End = p.curToken.End
+	return expression
+}
+
+// parseFilterExpression parses `| name(args)`, the current token is the pipe or,
+// for filter blocks, the filter keyword
+func (p *Parser) parseFilterExpression(left Expression) Expression {
+	expression := &FilterExpression{Token: p.curToken, Value: left, Span: p.curSpan()}
+	if left != nil {
+		expression.Start = left.GetSpan().Start
+	}
+
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	expression.Name = &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
+	if left == nil {
+		expression.Start = p.curToken.Start
+	}
+
+	if p.peekTokenIs(LEFT_BRACKET) {
+		p.nextToken()
+
+		arguments, keywords, ok := p.parseCallArguments()
+		if !ok {
+			return nil
+		}
+		expression.Arguments = arguments
+		expression.Keywords = keywords
+	}
+
+	expression.End = p.curToken.End
+	return expression
+}
+
+func (p *Parser) parseCallExpression(function Expression) Expression {
+	expression := &CallExpression{Token: p.curToken, Function: function, Span: Span{Start: function.GetSpan().Start}}
+
+	arguments, keywords, ok := p.parseCallArguments()
+	if !ok {
+		return nil
+	}
+
+	expression.Arguments = arguments
+	expression.Keywords = keywords
+	expression.End = p.curToken.End
+	return expression
+}
+
+// parseCallArguments parses positional and keyword arguments, the current token
+// is the opening bracket
+func (p *Parser) parseCallArguments() ([]Expression, []*KeywordArgument, bool) {
+	arguments := []Expression{}
+	keywords := []*KeywordArgument{}
+
+	for !p.peekTokenIs(RIGHT_BRACKET) {
+		p.nextToken()
+
+		if p.currentTokenIs(IDENT) && p.peekTokenIs(ASSIGN) {
+			keyword := &KeywordArgument{
+				Name: &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()},
+				Span: p.curSpan(),
+			}
+			p.nextToken()
+			p.nextToken()
+
+			keyword.Value = p.parseExpression(LOWEST)
+			if keyword.Value == nil {
+				return nil, nil, false
+			}
+			keyword.End = keyword.Value.GetSpan().End
+			keywords = append(keywords, keyword)
+		} else {
+			// *args and **kwargs are passed through as plain arguments
+			if p.currentTokenIs(ASTERIKS) || p.currentTokenIs(POWER) {
+				p.nextToken()
+			}
+
+			argument := p.parseExpression(LOWEST)
+			if argument == nil {
+				return nil, nil, false
+			}
+			arguments = append(arguments, argument)
+		}
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(RIGHT_BRACKET) {
+		return nil, nil, false
+	}
+	return arguments, keywords, true
+}
+
+func (p *Parser) parseAttributeExpression(object Expression) Expression {
+	expression := &AttributeExpression{Token: p.curToken, Object: object, Span: Span{Start: object.GetSpan().Start}}
+
+	p.nextToken()
+	if p.curToken.Value == "" || !(isLetter(p.curToken.Value[0]) || isDigit(p.curToken.Value[0])) {
+		p.addError(p.curToken.Start, "expected attribute name, got %v instead", p.curToken.Value)
+		return nil
+	}
+
+	expression.Attribute = &Identifier{Token: p.curToken, Value: p.curToken.Value, Span: p.curSpan()}
+	expression.End = p.curToken.End
+	return expression
+}
+
+func (p *Parser) parseIndexExpression(object Expression) Expression {
+	expression := &IndexExpression{Token: p.curToken, Object: object, Span: Span{Start: object.GetSpan().Start}}
+
+	p.nextToken()
+	if p.currentTokenIs(COLON) {
+		expression.Index = p.parseSliceExpression(nil)
+	} else {
+		index := p.parseExpression(LOWEST)
+		if index != nil && p.peekTokenIs(COLON) {
+			p.nextToken()
+			index = p.parseSliceExpression(index)
+		}
+		expression.Index = index
+	}
+
+	if expression.Index == nil || !p.expectPeek(END_COLLECTION) {
+		return nil
+	}
+
+	expression.End = p.curToken.End
+	return expression
+}
+
+// parseSliceExpression parses the rest of `lower:upper:step`, the current token
+// is the first colon
+func (p *Parser) parseSliceExpression(lower Expression) Expression {
+	slice := &SliceExpression{Token: p.curToken, Lower: lower, Span: p.curSpan()}
+	if lower != nil {
+		slice.Start = lower.GetSpan().Start
+	}
+
+	parsePart := func() Expression {
+		if p.peekTokenIs(COLON) || p.peekTokenIs(END_COLLECTION) {
+			return nil
+		}
+		p.nextToken()
+		return p.parseExpression(LOWEST)
+	}
+
+	slice.Upper = parsePart()
+	if p.peekTokenIs(COLON) {
+		p.nextToken()
+		slice.Step = parsePart()
+	}
+
+	slice.End = p.curToken.End
+	return slice
+}
+
+func (p *Parser) parseConditionalExpression(consequence Expression) Expression {
+	expression := &ConditionalExpression{Token: p.curToken, Consequence: consequence, Span: Span{Start: consequence.GetSpan().Start}}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(CONDITIONAL)
+	if expression.Condition == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(ELSE) {
+		p.nextToken()
+		p.nextToken()
+
+		expression.Alternative = p.parseExpression(LOWEST)
+		if expression.Alternative == nil {
+			return nil
+		}
+	}
+
+	expression.End = p.curToken.End
+	return expression
+}
@@ -33,3 +33,155 @@ func testSetStatement(t *testing.T, s *SetStatment, name string) bool {
 
 	return true
 }
+
+func TestExpressionPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{{ 1 + 2 * 3 }}", "(1 + (2 * 3))"},
+		{"{{ -a ** 2 }}", "((-a) ** 2)"},
+		{"{{ 2 ** 3 ** 2 }}", "(2 ** (3 ** 2))"},
+		{"{{ a ~ b + c }}", "((a ~ b) + c)"},
+		{"{{ not a and b or c }}", "(((not a) and b) or c)"},
+		{"{{ a not in b }}", "(a not in b)"},
+		{"{{ a < b == c }}", "((a < b) == c)"},
+		{"{{ a if b else c }}", "(a if b else c)"},
+		{"{{ a | upper | replace('x', 'y') }}", "((a | upper) | replace('x', 'y'))"},
+		{"{{ x is not divisibleby 3 }}", "(x is not divisibleby(3))"},
+		{"{{ x is defined and y }}", "((x is defined) and y)"},
+		{"{{ adapter.dispatch('m', 'pkg')(a, b=1) }}", "adapter.dispatch('m', 'pkg')(a, b=1)"},
+		{"{{ items[1:3] }}", "items[1:3]"},
+		{"{{ config.get('x')['y'] }}", "config.get('x')['y']"},
+		{"{{ [1, 2.5, 'a', true, none] }}", "[1, 2.5, 'a', true, none]"},
+		{"{{ {'a': 1, 'b': (1, 2)} }}", "{'a': 1, 'b': (1, 2)}"},
+		{"{{ 'a' \"b\" ~ c }}", "('a' \"b\" ~ c)"},
+		{"{{ [1.5e3, 2E-4, 1e+2, 3] }}", "[1.5e3, 2E-4, 1e+2, 3]"},
+	}
+
+	for _, tt := range tests {
+		file, errors := ParseTemplate(tt.input)
+		if len(errors) != 0 {
+			t.Errorf("%v: unexpected errors %v", tt.input, errors)
+			continue
+		}
+
+		stmt, ok := file.Statements[0].(*ExpressionStatement)
+		if !ok {
+			t.Errorf("%v: expected expression statement got %T", tt.input, file.Statements[0])
+			continue
+		}
+
+		if stmt.Value.String() != tt.expected {
+			t.Errorf("%v: expected %v got %v", tt.input, tt.expected, stmt.Value.String())
+		}
+	}
+}
+
+func TestBlockStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{% if a %}x{% elif b %}y{% else %}z{% endif %}", "if a;xelif b;yelse;zendif;"},
+		{"{% for k, v in items.items() if v recursive %}{{ k }}{% else %}none{% endfor %}", "for k, v in items.items() if v recursive;kelse;noneendfor;"},
+		{"{% macro hello(name, greeting='hi') %}{{ greeting }}{% endmacro %}", "macro hello(name, greeting='hi');greetingendmacro;"},
+		{"{% test not_null(model, column_name) %}select 1{% endtest %}", "test not_null(model, column_name);select 1endtest;"},
+		{"{% call(row) statement('x') %}select 1{% endcall %}", "call(row) statement('x');select 1endcall;"},
+		{"{% filter upper | trim %}x{% endfilter %}", "filter (upper | trim);xendfilter;"},
+		{"{% set body %}select 1{% endset %}", "set body;select 1endset;"},
+		{"{% set ns.total = ns.total + 1 %}", "set ns.total = (ns.total + 1);"},
+		{"{% set a, b = 1, 2 %}", "set a, b = (1, 2);"},
+		{"{% do results.append(1) %}", "do results.append(1);"},
+		{"{% raw %}{{ not parsed }}{% endraw %}", "{% raw %}{{ not parsed }}{% endraw %}"},
+		{"{#- a comment -#}", "{# a comment #}"},
+	}
+
+	for _, tt := range tests {
+		file, errors := ParseTemplate(tt.input)
+		if len(errors) != 0 {
+			t.Errorf("%v: unexpected errors %v", tt.input, errors)
+			continue
+		}
+
+		if len(file.Statements) != 1 {
+			t.Errorf("%v: expected 1 statement got %v", tt.input, len(file.Statements))
+			continue
+		}
+
+		if file.Statements[0].String() != tt.expected {
+			t.Errorf("%v: expected %v got %v", tt.input, tt.expected, file.Statements[0].String())
+		}
+
+		span := file.Statements[0].GetSpan()
+		if span.Start != 0 || span.End != len(tt.input) {
+			t.Errorf("%v: expected span 0-%v got %v-%v", tt.input, len(tt.input), span.Start, span.End)
+		}
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	file, errors := ParseTemplate("{{ 'select ' \"1\" }}{{ 1.5e3 }}{{ 2e-1 }}{% set a, b = 1, 2 %}")
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	if value := file.Statements[0].(*ExpressionStatement).Value.(*StringExpression); value.Value != "select 1" || value.Start != 3 || value.End != 16 {
+		t.Errorf("expected the strings to be joined but got %q %v-%v", value.Value, value.Start, value.End)
+	}
+
+	for i, expected := range []float64{1500, 0.2} {
+		value, ok := file.Statements[i+1].(*ExpressionStatement).Value.(*FloatExpression)
+		if !ok || value.Value != expected {
+			t.Errorf("expected %v but got %v", expected, file.Statements[i+1])
+		}
+	}
+
+	set := file.Statements[3].(*SetStatment)
+	if len(set.Targets()) != 2 || set.Targets()[1].Value != "b" || set.Name.Value != "a" {
+		t.Errorf("expected both targets but got %v", set.Targets())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "select * from {{ ref('orders') }}"
+
+	file, errors := ParseTemplate(input)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	calls := FindCalls(file, "ref")
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 call got %v", len(calls))
+	}
+
+	call := calls[0]
+	if input[call.Tag.Start:call.Tag.End] != "{{ ref('orders') }}" {
+		t.Errorf("wrong tag span %v", input[call.Tag.Start:call.Tag.End])
+	}
+
+	if input[call.Call.Start:call.Call.End] != "ref('orders')" {
+		t.Errorf("wrong call span %v", input[call.Call.Start:call.Call.End])
+	}
+
+	name := call.Call.Arguments[0].(*StringExpression)
+	span := name.ValueSpan()
+	if input[span.Start:span.End] != "orders" {
+		t.Errorf("wrong string span %v", input[span.Start:span.End])
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := "{% set = %}{{ ref('a') }}{% unknown x %}{{ ref('b') }}"
+
+	file, errors := ParseTemplate(input)
+	if len(errors) != 2 {
+		t.Errorf("expected 2 errors got %v", errors)
+	}
+
+	calls := FindCalls(file, "ref")
+	if len(calls) != 2 {
+		t.Fatalf("expected both refs to survive, got %v", len(calls))
+	}
+}
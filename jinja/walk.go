@@ -0,0 +1,195 @@
+package jinja
+
+import "slices"
+
+// Walk visits node and everything below it depth first, in source order. When
+// visit returns false the children of that node are skipped
+func Walk(node Node, visit func(Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+
+	for _, child := range children(node) {
+		Walk(child, visit)
+	}
+}
+
+// CallMatch is a call found in a template along with the tag it was written in
+type CallMatch struct {
+	Call *CallExpression
+	// Tag is the span of the enclosing {{ }} when the call is in an expression
+	// statement, otherwise it's the span of the call itself
+	Tag Span
+}
+
+// FindCalls returns every call to one of the given function names, or every call
+// when no names are given. Names are matched on the dotted name so
+// `dbt_utils.star` can be looked up as is
+func FindCalls(node Node, names ...string) []CallMatch {
+	matches := []CallMatch{}
+	var tag *Span
+
+	var visit func(Node) bool
+	visit = func(n Node) bool {
+		if stmt, ok := n.(*ExpressionStatement); ok {
+			span := stmt.Span
+			tag = &span
+			for _, child := range children(stmt) {
+				Walk(child, visit)
+			}
+			tag = nil
+			return false
+		}
+
+		call, ok := n.(*CallExpression)
+		if !ok {
+			return true
+		}
+
+		if len(names) > 0 && !slices.Contains(names, call.FunctionName()) {
+			return true
+		}
+
+		found := CallMatch{Call: call, Tag: call.Span}
+		if tag != nil {
+			found.Tag = *tag
+		}
+		matches = append(matches, found)
+		return true
+	}
+
+	Walk(node, visit)
+	return matches
+}
+
+// FindMacros returns every macro and test definition in the template
+func FindMacros(node Node) []*MacroStatement {
+	macros := []*MacroStatement{}
+	Walk(node, func(n Node) bool {
+		if macro, ok := n.(*MacroStatement); ok {
+			macros = append(macros, macro)
+		}
+		return true
+	})
+	return macros
+}
+
+func children(node Node) []Node {
+	nodes := []Node{}
+	add := func(children ...Node) {
+		for _, child := range children {
+			if child != nil && !isNilNode(child) {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	addStatements := func(statements []Statement) {
+		for _, stmt := range statements {
+			add(stmt)
+		}
+	}
+	addExpressions := func(expressions []Expression) {
+		for _, expression := range expressions {
+			add(expression)
+		}
+	}
+	addKeywords := func(keywords []*KeywordArgument) {
+		for _, keyword := range keywords {
+			add(keyword.Value)
+		}
+	}
+	addParameters := func(parameters []*Parameter) {
+		for _, parameter := range parameters {
+			add(parameter.Name, parameter.Default)
+		}
+	}
+
+	switch n := node.(type) {
+	case *File:
+		addStatements(n.Statements)
+	case *SetStatment:
+		for _, target := range n.Targets() {
+			add(target)
+		}
+		add(n.Attribute, n.Value)
+		addStatements(n.Body)
+	case *ExpressionStatement:
+		add(n.Value)
+	case *DoStatement:
+		add(n.Value)
+	case *IfStatement:
+		for _, branch := range n.Branches {
+			add(branch.Condition)
+			addStatements(branch.Consequence)
+		}
+		addStatements(n.Alternative)
+	case *ForStatement:
+		for _, target := range n.Targets {
+			add(target)
+		}
+		add(n.Iterable, n.Condition)
+		addStatements(n.Body)
+		addStatements(n.Alternative)
+	case *MacroStatement:
+		add(n.Name)
+		addParameters(n.Parameters)
+		addStatements(n.Body)
+	case *CallBlockStatement:
+		addParameters(n.Parameters)
+		add(n.Call)
+		addStatements(n.Body)
+	case *FilterBlockStatement:
+		add(n.Filter)
+		addStatements(n.Body)
+	case *BlockStatement:
+		add(n.Name)
+		addStatements(n.Body)
+	case *ListExpression:
+		addExpressions(n.Elements)
+	case *TupleExpression:
+		addExpressions(n.Elements)
+	case *DictExpression:
+		for _, pair := range n.Pairs {
+			add(pair.Key, pair.Value)
+		}
+	case *PrefixExpression:
+		add(n.Right)
+	case *InfixExpression:
+		add(n.Left, n.Right)
+	case *ConditionalExpression:
+		add(n.Consequence, n.Condition, n.Alternative)
+	case *AttributeExpression:
+		add(n.Object, n.Attribute)
+	case *IndexExpression:
+		add(n.Object, n.Index)
+	case *SliceExpression:
+		add(n.Lower, n.Upper, n.Step)
+	case *CallExpression:
+		add(n.Function)
+		addExpressions(n.Arguments)
+		addKeywords(n.Keywords)
+	case *FilterExpression:
+		add(n.Value, n.Name)
+		addExpressions(n.Arguments)
+		addKeywords(n.Keywords)
+	case *TestExpression:
+		add(n.Value, n.Name)
+		addExpressions(n.Arguments)
+	}
+
+	return nodes
+}
+
+// isNilNode catches typed nil pointers stored in an interface, e.g. an unset
+// *Identifier passed as a Node
+func isNilNode(node Node) bool {
+	switch n := node.(type) {
+	case *Identifier:
+		return n == nil
+	case *CallExpression:
+		return n == nil
+	case *FilterExpression:
+		return n == nil
+	}
+	return false
+}
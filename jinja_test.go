@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"slices"
+	"testing"
+)
 
 func TestGettingRefTags(t *testing.T) {
 	testWrapper(`{{ ref('my_first_dbt_model')}}`, []string{"my_first_dbt_model"}, []int{0}, 1, t)
@@ -50,3 +53,104 @@ func testMacroWrapper(content string, expectedName []string, expectedPosition []
 		}
 	}
 }
+
+func TestGettingSourceTags(t *testing.T) {
+	parser := NewJinjaParser()
+	content := `select * from {{ source('raw', 'orders') }}`
+
+	sources := parser.GetAllSourceTags(content)
+	if len(sources) != 1 {
+		t.Fatalf("expected 1 source but got %v", len(sources))
+	}
+
+	if sources[0].SourceName != "raw" || sources[0].TableName != "orders" || sources[0].Range.Start != 14 {
+		t.Errorf("got wrong source %+v", sources[0])
+	}
+
+	if macros := parser.GetMacros(content); len(macros) != 0 {
+		t.Errorf("source should not be treated as a macro %v", macros)
+	}
+}
+
+func TestGettingSnapshotDefinitions(t *testing.T) {
+	parser := NewJinjaParser()
+	content := "{% snapshot orders_snapshot %}\n{{ config(unique_key='id') }}\nselect * from {{ source('raw', 'orders') }}\n{% endsnapshot %}"
+
+	snapshots := parser.GetSnapshotDefinitions(content)
+	if len(snapshots) != 1 {
+		t.Fatalf("expected 1 snapshot but got %v", len(snapshots))
+	}
+
+	if snapshots[0].ModelName != "orders_snapshot" || snapshots[0].Range.Start != 0 || snapshots[0].Range.End != len(content) {
+		t.Errorf("got wrong snapshot %+v", snapshots[0])
+	}
+}
+
+func TestMacroDefinitionArguments(t *testing.T) {
+	parser := NewJinjaParser()
+	content := "{%- macro cents_to_dollars(column, scale=2, format='x') -%}\n{{ column }}\n{%- endmacro %}"
+
+	macros := parser.GetMacroDefinitions(content)
+	if len(macros) != 1 {
+		t.Fatalf("expected 1 macro but got %v", len(macros))
+	}
+
+	expected := []MacroArgument{{Name: "column"}, {Name: "scale", Default: "2"}, {Name: "format", Default: "'x'"}}
+	if !slices.Equal(macros[0].Arguments, expected) {
+		t.Errorf("expected %v but got %v", expected, macros[0].Arguments)
+	}
+}
+
+func TestNamespacedMacroCalls(t *testing.T) {
+	parser := NewJinjaParser()
+	content := "select {{ dbt_utils.star(ref('orders')) }}, {{ adapter.quote('x').upper() }}"
+
+	macros := parser.GetMacros(content)
+	if len(macros) != 2 {
+		t.Fatalf("expected 2 macros but got %+v", macros)
+	}
+
+	if macros[0].QualifiedName() != "dbt_utils.star" || content[macros[0].NameRange.Start:macros[0].NameRange.End] != "dbt_utils.star" {
+		t.Errorf("got wrong macro %+v", macros[0])
+	}
+
+	if macros[1].QualifiedName() != "adapter.quote" {
+		t.Errorf("got wrong macro %+v", macros[1])
+	}
+}
+
+func TestGettingVarTags(t *testing.T) {
+	parser := NewJinjaParser()
+	content := `where created_at > '{{ var("start_date") }}' and {{ var('limit', 10) }} {{ var(name) }}`
+
+	vars := parser.GetAllVarTags(content)
+	if len(vars) != 2 {
+		t.Fatalf("expected 2 vars but got %v", vars)
+	}
+
+	if vars[0].Name != "start_date" || vars[0].HasDefault || content[vars[0].NameRange.Start:vars[0].NameRange.End] != "start_date" {
+		t.Errorf("got wrong var %+v", vars[0])
+	}
+
+	if vars[1].Name != "limit" || !vars[1].HasDefault || vars[1].Default != "10" {
+		t.Errorf("got wrong var %+v", vars[1])
+	}
+
+	if macros := parser.GetMacros(content); len(macros) != 0 {
+		t.Errorf("var should not be treated as a macro %v", macros)
+	}
+}
+
+func TestParserReusesTemplate(t *testing.T) {
+	parser := NewJinjaParser()
+	content := "select * from {{ ref('orders') }} where {{ var('x') }}"
+
+	first := parser.parse(content)
+	if len(parser.GetAllRefTags(content)) != 1 || len(parser.GetAllVarTags(content)) != 1 || parser.parse(content) != first {
+		t.Errorf("expected the helpers to share the parsed template")
+	}
+
+	if parser.parse("{{ ref('customers') }}") == first {
+		t.Errorf("new content should be parsed again")
+	}
+}
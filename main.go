@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/tliron/commonlog"
 	"github.com/tliron/glsp"
@@ -18,14 +19,21 @@ import (
 const lsName = "dbt_lsp"
 
 var (
-	version  string = "0.0.1"
-	handler  protocol.Handler
-	manifest Manifest
-	settings ProjectSettings
-	ROOT_DIR string
+	version string = "0.0.1"
+	handler protocol.Handler
 )
 
 func main() {
+	// editors start the server with flags like --stdio, only a known subcommand
+	// keeps it from starting
+	if len(os.Args) > 1 && os.Args[1] == "graph" {
+		if err := runGraph(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// This increases logging verbosity (optional)
 	ex, err := os.Executable()
 	if err != nil {
@@ -34,49 +42,63 @@ func main() {
 	log := filepath.Join(filepath.Dir(ex), "log.txt")
 	commonlog.Configure(1, &log)
 
+	workspace := NewWorkspace()
 	handler = protocol.Handler{
-		Initialize:                     initialize,
-		Initialized:                    initialized,
-		Shutdown:                       shutdown,
-		SetTrace:                       setTrace,
-		TextDocumentDefinition:         definitionHandler,
-		TextDocumentHover:              hoverHandler,
-		WorkspaceDidChangeWatchedFiles: fileChanged,
+		Initialize:                       workspace.initialize,
+		Initialized:                      workspace.initialized,
+		Shutdown:                         shutdown,
+		SetTrace:                         setTrace,
+		TextDocumentDefinition:           workspace.definitionHandler,
+		TextDocumentHover:                workspace.hoverHandler,
+		TextDocumentCompletion:           workspace.completionHandler,
+		TextDocumentDidOpen:              workspace.didOpen,
+		TextDocumentDidChange:            workspace.didChange,
+		TextDocumentDidSave:              workspace.didSave,
+		TextDocumentDidClose:             workspace.didClose,
+		TextDocumentReferences:           workspace.referencesHandler,
+		TextDocumentPrepareRename:        workspace.prepareRenameHandler,
+		TextDocumentRename:               workspace.renameHandler,
+		TextDocumentDocumentSymbol:       workspace.documentSymbolHandler,
+		WorkspaceSymbol:                  workspace.workspaceSymbolHandler,
+		TextDocumentSignatureHelp:        workspace.signatureHelpHandler,
+		TextDocumentImplementation:       workspace.implementationHandler,
+		WorkspaceDidChangeWatchedFiles:   workspace.fileChanged,
+		TextDocumentPrepareCallHierarchy: workspace.prepareCallHierarchyHandler,
+		CallHierarchyIncomingCalls:       workspace.incomingCallsHandler,
+		CallHierarchyOutgoingCalls:       workspace.outgoingCallsHandler,
+		TextDocumentCodeLens:             workspace.codeLensHandler,
+		WorkspaceExecuteCommand:          workspace.executeCommandHandler,
 	}
 
 	server := server.NewServer(&handler, lsName, false)
 	server.RunStdio()
 }
 
-func initialize(context *glsp.Context, params *protocol.InitializeParams) (any, error) {
+func (w *Workspace) initialize(context *glsp.Context, params *protocol.InitializeParams) (any, error) {
 	initLog := commonlog.GetLoggerf("%s.init", lsName)
 
-	initLog.Infof("ROOT_DIR %v", params.WorkspaceFolders)
-	ROOT_DIR = params.WorkspaceFolders[0].URI
-
-	settings, err := LoadSettings(ROOT_DIR)
-	if err != nil {
+	initLog.Infof("root %v", params.WorkspaceFolders)
+	if err := w.Load(params.WorkspaceFolders[0].URI); err != nil {
 		initLog.Errorf("ERROR %v", err)
 		return nil, err
 	}
 
-	schemas, err := settings.GetSchemaFiles()
-	if err != nil {
-		initLog.Errorf("Could not load schema files %v", err)
-	}
-
-	manifest, err = settings.LoadManifestFile()
-	if err != nil {
-		initLog.Errorf("could not load manifest file %v", err)
+	if workspace := params.Capabilities.Workspace; workspace != nil && workspace.DidChangeWatchedFiles != nil && workspace.DidChangeWatchedFiles.DynamicRegistration != nil {
+		w.SetWatchFiles(*workspace.DidChangeWatchedFiles.DynamicRegistration)
 	}
-
-	manifest, err = settings.PredictManifestFile(settings.Name, schemas)
-	if err != nil {
-		initLog.Errorf("Could not predict manifest file %v", err)
-		return nil, err
+	if workspace := params.Capabilities.Workspace; workspace != nil && workspace.WorkspaceEdit != nil {
+		w.SetDocumentChanges(workspace.WorkspaceEdit.DocumentChanges != nil && *workspace.WorkspaceEdit.DocumentChanges)
+		w.SetRenameFiles(slices.Contains(workspace.WorkspaceEdit.ResourceOperations, protocol.ResourceOperationKindRename))
 	}
 
 	capabilities := handler.CreateServerCapabilities()
+	capabilities.CompletionProvider.TriggerCharacters = []string{"'", "\"", "."}
+	capabilities.RenameProvider = protocol.RenameOptions{PrepareProvider: &protocol.True}
+	capabilities.SignatureHelpProvider = &protocol.SignatureHelpOptions{
+		TriggerCharacters:   []string{"(", ","},
+		RetriggerCharacters: []string{"="},
+	}
+	capabilities.ExecuteCommandProvider = &protocol.ExecuteCommandOptions{Commands: commands}
 	initLog.Info("Returning initialized")
 	return protocol.InitializeResult{
 		Capabilities: capabilities,
@@ -87,7 +109,10 @@ func initialize(context *glsp.Context, params *protocol.InitializeParams) (any,
 	}, nil
 }
 
-func initialized(context *glsp.Context, params *protocol.InitializedParams) error {
+func (w *Workspace) initialized(context *glsp.Context, params *protocol.InitializedParams) error {
+	if w.WatchFiles() {
+		w.registerFileWatchers(context)
+	}
 	return nil
 }
 
@@ -115,8 +140,9 @@ func highlighHandler(_ *glsp.Context, _ *protocol.DocumentHighlightParams) ([]pr
 	return documentHighlights, nil
 }
 
-func definitionHandler(context *glsp.Context, params *protocol.DefinitionParams) (any, error) {
+func (w *Workspace) definitionHandler(context *glsp.Context, params *protocol.DefinitionParams) (any, error) {
 	definitionLog := commonlog.GetLoggerf("%s.definition", lsName)
+	manifest := w.Manifest()
 	definitionLog.Infof("getting definition: %v", params.TextDocument.URI)
 
 	file := getModelNameFromFilePath(params.TextDocument.URI)
@@ -127,8 +153,15 @@ func definitionHandler(context *glsp.Context, params *protocol.DefinitionParams)
 		return nil, nil
 	}
 
-	model, err := val.GetDefinition(DefinitionRequest{
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		definitionLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	links, err := val.GetDefinition(DefinitionRequest{
 		FileUri:     params.TextDocument.URI,
+		Content:     string(fileContent),
 		Position:    params.Position,
 		Manifest:    manifest,
 		ProjectName: manifest.Metadata.ProjectName,
@@ -138,53 +171,152 @@ func definitionHandler(context *glsp.Context, params *protocol.DefinitionParams)
 		return nil, err
 	}
 
-	definitionLog.Infof("Got definition: %v", model.FileName)
-	return protocol.Location{
-		URI: model.FileName,
-		Range: protocol.Range{
-			Start: protocol.Position{Line: 0, Character: 0},
-			End:   protocol.Position{Line: 0, Character: 0},
-		},
-	}, nil
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	definitionLog.Infof("Got definition: %v", links[0].TargetURI)
+	return links, nil
 }
 
-func hoverHandler(context *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
+func (w *Workspace) hoverHandler(context *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
 	definitionLog := commonlog.GetLoggerf("%s.hover", lsName)
+	settings, manifest := w.Snapshot()
 
-	file := getModelNameFromFilePath(params.TextDocument.URI)
-	key := fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, file)
-
-	val, ok := manifest.Nodes[key]
-	if !ok {
-		definitionLog.Infof("could not find initial key %v", key)
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		definitionLog.Infof("couldn't read file %v", err)
 		return nil, nil
 	}
 
-	model, err := val.GetDefinition(DefinitionRequest{
-		FileUri:  params.TextDocument.URI,
-		Position: params.Position,
-	})
-	if err != nil {
-		definitionLog.Infof("getting the definition failed %v", err)
-		return nil, err
+	content := string(fileContent)
+	rawPosition := getRawPositionInFile(content, params.Position.Line, params.Position.Character)
+	parser := NewJinjaParser()
+
+	for _, tag := range parser.GetAllSourceTags(content) {
+		if rawPosition < tag.Range.Start || rawPosition > tag.Range.End {
+			continue
+		}
+
+		key, source, ok := manifest.FindSource(tag.SourceName, tag.TableName)
+		if !ok {
+			definitionLog.Infof("could not find source %v.%v", tag.SourceName, tag.TableName)
+			return nil, nil
+		}
+
+		definitionLog.Infof("hovering source %v", key)
+		return &protocol.Hover{Contents: protocol.MarkupContent{
+			Kind:  protocol.MarkupKindMarkdown,
+			Value: source.GetHoverText(),
+		}}, nil
 	}
 
-	key = fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, model)
+	for _, tag := range parser.GetAllRefTags(content) {
+		if rawPosition < tag.Range.Start || rawPosition > tag.Range.End {
+			continue
+		}
+
+		key := manifest.GetRefKey(tag)
+		referencedNode, ok := manifest.Nodes[key]
+		if !ok {
+			definitionLog.Infof("could not referenced key %v", key)
+			return nil, nil
+		}
+
+		return &protocol.Hover{Contents: protocol.MarkupContent{
+			Kind:  protocol.MarkupKindMarkdown,
+			Value: referencedNode.GetHoverText(key),
+		}}, nil
+	}
 
-	referencedNode, ok := manifest.Nodes[key]
-	if !ok {
-		definitionLog.Infof("could not referenced key %v", key)
+	for _, block := range parser.GetConfigBlocks(content) {
+		if rawPosition < block.Range.Start || rawPosition > block.Range.End {
+			continue
+		}
+
+		path, err := CleanUri(params.TextDocument.URI)
+		if err != nil {
+			definitionLog.Infof("couldn't clean uri %v", err)
+			return nil, nil
+		}
+		config := mergeConfigBlocks(settings.GetModelConfigForFile(manifest.Metadata.ProjectName, path), parser.GetConfigBlocks(content))
+
+		for _, argument := range block.Arguments {
+			if rawPosition < argument.KeyRange.Start || rawPosition > argument.KeyRange.End {
+				continue
+			}
+
+			hoverRange := getRangeInFile(content, argument.KeyRange)
+			return &protocol.Hover{
+				Contents: protocol.MarkupContent{Kind: protocol.MarkupKindMarkdown, Value: getConfigHoverText(config, argument.Key)},
+				Range:    &hoverRange,
+			}, nil
+		}
+
+		return &protocol.Hover{Contents: protocol.MarkupContent{
+			Kind:  protocol.MarkupKindMarkdown,
+			Value: getConfigHoverText(config, ""),
+		}}, nil
+	}
+
+	for _, reference := range parser.GetAllVarTags(content) {
+		if rawPosition < reference.Range.Start || rawPosition > reference.Range.End {
+			continue
+		}
+
+		hoverRange := getRangeInFile(content, reference.NameRange)
+		projectVar, ok := manifest.FindVar(manifest.Metadata.ProjectName, reference.Name)
+		if !ok {
+			if !reference.HasDefault {
+				return nil, nil
+			}
+
+			return &protocol.Hover{
+				Contents: protocol.MarkupContent{
+					Kind:  protocol.MarkupKindMarkdown,
+					Value: fmt.Sprintf("**var** `%s`\n\nnot defined in dbt_project.yml, default: `%s`", reference.Name, reference.Default),
+				},
+				Range: &hoverRange,
+			}, nil
+		}
+
+		return &protocol.Hover{
+			Contents: protocol.MarkupContent{Kind: protocol.MarkupKindMarkdown, Value: projectVar.GetHoverText(reference)},
+			Range:    &hoverRange,
+		}, nil
+	}
+
+	for _, macro := range parser.GetMacros(content) {
+		if rawPosition < macro.NameRange.Start || rawPosition > macro.NameRange.End {
+			continue
+		}
+
+		key, definition, ok := manifest.FindMacro(macro.QualifiedName())
+		if !ok {
+			definitionLog.Infof("could not find macro %v", macro.QualifiedName())
+			return nil, nil
+		}
+
+		hoverRange := getRangeInFile(content, macro.NameRange)
+		return &protocol.Hover{
+			Contents: protocol.MarkupContent{Kind: protocol.MarkupKindMarkdown, Value: definition.GetHoverText(key)},
+			Range:    &hoverRange,
+		}, nil
+	}
+
+	if positionWithinRange(rawPosition, parser.GetJinjaPositions(content)) {
 		return nil, nil
 	}
 
-	return &protocol.Hover{Contents: referencedNode.Description}, nil
-}
+	nodeKey := fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, getModelNameFromFilePath(params.TextDocument.URI))
+	column, ok := getColumnAtPosition(manifest, nodeKey, content, rawPosition)
+	if !ok {
+		return nil, nil
+	}
 
-func fileChanged(context *glsp.Context, params *protocol.DidChangeWatchedFilesParams) error {
-	// for _, uri := range params.Changes {
-	// 	file := strings.ReplaceAll(uri.URI, "file://", "")
-	//
-	//
-	// }
-	return nil
+	hoverRange := getRangeInFile(content, column.Range)
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: protocol.MarkupKindMarkdown, Value: column.GetHoverText()},
+		Range:    &hoverRange,
+	}, nil
 }
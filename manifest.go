@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// The manifest schema versions we can read, dbt 1.6 writes v10 and 1.8 writes v12
+const (
+	minManifestVersion = 10
+	maxManifestVersion = 12
+)
+
+var manifestVersionRegex = regexp.MustCompile(`/manifest/v(\d+)\.json$`)
+
+// ManifestResource holds the fields every resource in the manifest shares
+type ManifestResource struct {
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	ResourceType string  `json:"resource_type"`
+	PackageName  string  `json:"package_name"`
+	OriginalPath string  `json:"original_file_path"`
+	UniqueId     string  `json:"unique_id"`
+	Depends      Depends `json:"depends_on"`
+}
+
+type Owner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type Exposure struct {
+	ManifestResource
+	Type     string   `json:"type"`
+	Label    string   `json:"label"`
+	Maturity string   `json:"maturity"`
+	Url      string   `json:"url"`
+	Owner    Owner    `json:"owner"`
+	Tags     []string `json:"tags"`
+	Fqn      []string `json:"fqn"`
+}
+
+type Metric struct {
+	ManifestResource
+	Label string   `json:"label"`
+	Type  string   `json:"type"`
+	Tags  []string `json:"tags"`
+	Fqn   []string `json:"fqn"`
+}
+
+type SemanticModel struct {
+	ManifestResource
+	// Model is the ref() the semantic model is built on, like ref('orders')
+	Model string   `json:"model"`
+	Label string   `json:"label"`
+	Fqn   []string `json:"fqn"`
+}
+
+// Doc is a {% docs %} block
+type Doc struct {
+	Name          string `json:"name"`
+	PackageName   string `json:"package_name"`
+	OriginalPath  string `json:"original_file_path"`
+	UniqueId      string `json:"unique_id"`
+	BlockContents string `json:"block_contents"`
+}
+
+type Group struct {
+	Name         string `json:"name"`
+	PackageName  string `json:"package_name"`
+	OriginalPath string `json:"original_file_path"`
+	UniqueId     string `json:"unique_id"`
+	Owner        Owner  `json:"owner"`
+}
+
+// Selector is a selector from selectors.yml, Definition is kept as dbt wrote it
+type Selector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Definition  any    `json:"definition"`
+}
+
+// getManifestVersion reads the version out of a schema url like
+// https://schemas.getdbt.com/dbt/manifest/v12.json
+func getManifestVersion(schemaVersion string) (int, error) {
+	match := manifestVersionRegex.FindStringSubmatch(schemaVersion)
+	if match == nil {
+		return 0, fmt.Errorf("unknown manifest schema %q", schemaVersion)
+	}
+
+	version, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, fmt.Errorf("unknown manifest schema %q", schemaVersion)
+	}
+	return version, nil
+}
+
+// parseManifest decodes a manifest.json written by dbt, it fails when the schema
+// is one we don't know how to read
+func parseManifest(content []byte) (Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return Manifest{}, err
+	}
+
+	version, err := getManifestVersion(manifest.Metadata.DbtSchemaVersion)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if version < minManifestVersion || version > maxManifestVersion {
+		return Manifest{}, fmt.Errorf("manifest schema v%d is not supported, expected v%d to v%d", version, minManifestVersion, maxManifestVersion)
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	content := `{
+		"metadata": {"dbt_schema_version": "https://schemas.getdbt.com/dbt/manifest/%s.json", "project_name": "jaffle_shop"},
+		"nodes": {
+			"model.jaffle_shop.orders": {
+				"name": "orders",
+				"resource_type": "model",
+				"package_name": "jaffle_shop",
+				"patch_path": "jaffle_shop://models/schema.yml",
+				"tags": ["finance"],
+				"fqn": ["jaffle_shop", "orders"],
+				"columns": {"order_id": {"name": "order_id", "data_type": "integer"}}
+			}
+		},
+		"sources": {"source.jaffle_shop.raw.orders": {"name": "orders", "source_name": "raw", "resource_type": "source"}},
+		"exposures": {"exposure.jaffle_shop.weekly": {"name": "weekly", "type": "dashboard", "owner": {"name": "finance"}, "depends_on": {"nodes": ["model.jaffle_shop.orders"]}}},
+		"metrics": {"metric.jaffle_shop.revenue": {"name": "revenue", "type": "simple", "label": "Revenue"}},
+		"semantic_models": {"semantic_model.jaffle_shop.orders": {"name": "orders", "model": "ref('orders')"}},
+		"docs": {"doc.jaffle_shop.orders": {"name": "orders", "block_contents": "One row per order"}},
+		"groups": {"group.jaffle_shop.finance": {"name": "finance", "owner": {"email": "finance@example.com"}}},
+		"selectors": {"nightly": {"name": "nightly", "definition": {"method": "tag", "value": "nightly"}}},
+		"parent_map": {"model.jaffle_shop.orders": ["source.jaffle_shop.raw.orders"]},
+		"child_map": {"source.jaffle_shop.raw.orders": ["model.jaffle_shop.orders"]}
+	}`
+
+	for _, version := range []string{"v10", "v11", "v12"} {
+		manifest, err := parseManifest([]byte(fmt.Sprintf(content, version)))
+		if err != nil {
+			t.Fatalf("%v should be supported but got %v", version, err)
+		}
+
+		node := manifest.Nodes["model.jaffle_shop.orders"]
+		if node.PatchPath != "jaffle_shop://models/schema.yml" || node.Tags[0] != "finance" || len(node.Fqn) != 2 || node.Columns["order_id"].DataType != "integer" {
+			t.Errorf("node fields were not decoded %+v", node)
+		}
+		if manifest.Exposures["exposure.jaffle_shop.weekly"].Depends.Nodes[0] != "model.jaffle_shop.orders" || manifest.Exposures["exposure.jaffle_shop.weekly"].Owner.Name != "finance" {
+			t.Errorf("exposure was not decoded %+v", manifest.Exposures)
+		}
+		if manifest.Metrics["metric.jaffle_shop.revenue"].Label != "Revenue" || manifest.SemanticModels["semantic_model.jaffle_shop.orders"].Model != "ref('orders')" {
+			t.Errorf("metrics or semantic models were not decoded %+v %+v", manifest.Metrics, manifest.SemanticModels)
+		}
+		if manifest.Docs["doc.jaffle_shop.orders"].BlockContents == "" || manifest.Groups["group.jaffle_shop.finance"].Owner.Email == "" || manifest.Selectors["nightly"].Definition == nil {
+			t.Errorf("docs, groups or selectors were not decoded")
+		}
+		if manifest.ParentMap["model.jaffle_shop.orders"][0] != "source.jaffle_shop.raw.orders" || len(manifest.ChildMap) != 1 {
+			t.Errorf("lineage maps were not decoded %v %v", manifest.ParentMap, manifest.ChildMap)
+		}
+	}
+
+	for _, version := range []string{"v9", "v13", "latest"} {
+		if _, err := parseManifest([]byte(fmt.Sprintf(content, version))); err == nil {
+			t.Errorf("%v should not be supported", version)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tliron/commonlog"
+)
+
+// mergedResourceTypes are the compiled nodes we take from the manifest
+var mergedResourceTypes = []string{"model", "seed", "snapshot"}
+
+// mergeManifests layers what we predicted from the files on disk over the manifest
+// dbt compiled. The compiled manifest knows things we can't work out ourselves so
+// its nodes win, unless their file changed since dbt wrote the manifest. The
+// locations we track, like the schema yaml entry of a model, only come from the
+// prediction and are kept either way
+func (settings ProjectSettings) mergeManifests(compiled, predicted Manifest) Manifest {
+	logger := commonlog.GetLogger("models.mergeManifests")
+	roots := settings.getPackageRoots()
+
+	merged := predicted.Clone()
+	stale := 0
+
+	for key, node := range compiled.Nodes {
+		// tests, analyses and the like aren't things that can be ref'd, keeping them
+		// would show them in symbols and lineage as if they were models
+		if !slices.Contains(mergedResourceTypes, node.ResourceType) {
+			continue
+		}
+
+		path, ok := resolveManifestPath(roots, node.PackageName, node.OriginalPath)
+		if !ok {
+			continue
+		}
+		node.OriginalPath = path
+		if node.CompiledPath != "" {
+			node.CompiledPath = fmt.Sprintf("file://%v", filepath.Join(settings.GetRootDirectory(), node.CompiledPath))
+		}
+
+		// the file was deleted since dbt wrote the manifest
+		fresh, exists := isFresh(node)
+		if !exists {
+			continue
+		}
+
+		local, predictedNode := merged.Nodes[key]
+		if predictedNode && !fresh {
+			stale++
+			continue
+		}
+
+		if predictedNode {
+			node = mergeNode(node, local)
+		}
+		merged.Nodes[key] = node
+	}
+
+	for key, macro := range compiled.Macros {
+		if _, ok := merged.Macros[key]; ok {
+			continue
+		}
+
+		path, ok := resolveManifestPath(roots, macro.PackageName, macro.OriginalPath)
+		if !ok {
+			continue
+		}
+		macro.OriginalPath = path
+		merged.Macros[key] = macro
+	}
+
+	for key, source := range compiled.Sources {
+		if _, ok := merged.Sources[key]; ok {
+			continue
+		}
+
+		path, ok := resolveManifestPath(roots, source.PackageName, source.OriginalPath)
+		if !ok {
+			continue
+		}
+		source.OriginalPath = path
+		merged.Sources[key] = source
+	}
+
+	merged.Exposures = resolveResourcePaths(roots, compiled.Exposures, func(exposure *Exposure) *ManifestResource { return &exposure.ManifestResource })
+	merged.Metrics = resolveResourcePaths(roots, compiled.Metrics, func(metric *Metric) *ManifestResource { return &metric.ManifestResource })
+	merged.SemanticModels = resolveResourcePaths(roots, compiled.SemanticModels, func(model *SemanticModel) *ManifestResource { return &model.ManifestResource })
+	merged.Docs = compiled.Docs
+	merged.Groups = compiled.Groups
+	merged.Selectors = compiled.Selectors
+	merged.ParentMap = compiled.ParentMap
+	merged.ChildMap = compiled.ChildMap
+	merged.Metadata.DbtSchemaVersion = compiled.Metadata.DbtSchemaVersion
+	merged.Metadata.DbtVersion = compiled.Metadata.DbtVersion
+	merged.Metadata.GeneratedAt = compiled.Metadata.GeneratedAt
+
+	merged.resolveRefKeys()
+
+	logger.Infof("merged %v compiled nodes, %v changed since the manifest was written", len(compiled.Nodes), stale)
+	return merged
+}
+
+// mergeNode keeps the compiled node and adds what only the prediction knows
+func mergeNode(compiled, predicted Node) Node {
+	compiled.Range = predicted.Range
+	compiled.SchemaPath = predicted.SchemaPath
+	compiled.SchemaRange = predicted.SchemaRange
+	compiled.SchemaNameRange = predicted.SchemaNameRange
+
+	columns := map[string]NodeColumn{}
+	for name, column := range compiled.Columns {
+		if local, ok := predicted.Columns[name]; ok {
+			column.Range = local.Range
+			column.NameRange = local.NameRange
+		}
+		columns[name] = column
+	}
+	compiled.Columns = columns
+
+	return compiled
+}
+
+// resolveResourcePaths makes the paths of resources that only the compiled
+// manifest knows about absolute, resources whose package isn't installed are kept
+// with the path dbt wrote
+func resolveResourcePaths[T any](roots map[string]string, resources map[string]T, resource func(*T) *ManifestResource) map[string]T {
+	resolved := make(map[string]T, len(resources))
+	for key, value := range resources {
+		common := resource(&value)
+		if path, ok := resolveManifestPath(roots, common.PackageName, common.OriginalPath); ok {
+			common.OriginalPath = path
+		}
+		resolved[key] = value
+	}
+	return resolved
+}
+
+// isFresh compares the checksum dbt recorded with the file as it is now, it also
+// tells whether the file still exists. dbt hashes the contents without the
+// surrounding whitespace
+func isFresh(node Node) (bool, bool) {
+	path, err := CleanUri(node.OriginalPath)
+	if err != nil {
+		return false, false
+	}
+
+	fileContent, err := os.ReadFile(path)
+	if err != nil {
+		return false, false
+	}
+
+	if node.ResourceType == "seed" {
+		return isSeedFresh(node.Checksum, fileContent), true
+	}
+	return node.Checksum.Name == "sha256" && node.Checksum.Checksum == getFileChecksum(fileContent), true
+}
+
+// isSeedFresh checks a csv against its checksum. dbt only records the path of
+// seeds too big to hash, those can't be checked so the compiled node is trusted,
+// and depending on the version the smaller ones are hashed with or without the
+// surrounding whitespace
+func isSeedFresh(checksum Checksum, fileContent []byte) bool {
+	switch checksum.Name {
+	case "path":
+		return true
+	case "sha256":
+		sum := sha256.Sum256(fileContent)
+		return checksum.Checksum == getFileChecksum(fileContent) || checksum.Checksum == hex.EncodeToString(sum[:])
+	}
+	return false
+}
+
+func getFileChecksum(fileContent []byte) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(string(fileContent))))
+	return hex.EncodeToString(sum[:])
+}
+
+// getPackageRoots maps the project and every installed package to its directory,
+// paths in the compiled manifest are relative to them
+func (settings ProjectSettings) getPackageRoots() map[string]string {
+	roots := map[string]string{settings.Name: settings.GetRootDirectory()}
+	for _, dependency := range settings.GetPackages() {
+		roots[dependency.Name] = dependency.GetRootDirectory()
+	}
+	return roots
+}
+
+func resolveManifestPath(roots map[string]string, packageName, path string) (string, bool) {
+	if strings.HasPrefix(path, "file://") {
+		return path, true
+	}
+
+	root, ok := roots[packageName]
+	if !ok || path == "" {
+		return "", false
+	}
+	return fmt.Sprintf("file://%v", filepath.Join(root, path)), true
+}
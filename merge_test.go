@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeCompiledManifest(t *testing.T) {
+	root := copyProject(t, "./tests/project")
+	orders, _ := os.ReadFile(filepath.Join(root, "models", "orders.sql"))
+
+	customers := []byte("id,first_name\n1,Michael\n")
+	os.MkdirAll(filepath.Join(root, "seeds"), 0755)
+	os.WriteFile(filepath.Join(root, "seeds", "raw_customers.csv"), customers, 0644)
+	os.WriteFile(filepath.Join(root, "seeds", "raw_orders.csv"), []byte("id,user_id\n1,1\n"), 0644)
+	customersSum := sha256.Sum256(customers)
+
+	compiled := map[string]any{
+		"metadata": map[string]any{"project_name": "jaffle_shop", "dbt_schema_version": "https://schemas.getdbt.com/dbt/manifest/v12.json"},
+		"nodes": map[string]any{
+			"model.jaffle_shop.orders": map[string]any{
+				"name":               "orders",
+				"resource_type":      "model",
+				"package_name":       "jaffle_shop",
+				"original_file_path": "models/orders.sql",
+				"compiled_path":      "build/compiled/jaffle_shop/models/orders.sql",
+				"checksum":           map[string]any{"name": "sha256", "checksum": getFileChecksum(append(orders, '\n'))},
+				"config":             map[string]any{"materialized": "incremental"},
+				"columns":            map[string]any{"order_id": map[string]any{"name": "order_id", "data_type": "integer"}},
+			},
+			"model.jaffle_shop.stg_orders": map[string]any{
+				"name":               "stg_orders",
+				"resource_type":      "model",
+				"package_name":       "jaffle_shop",
+				"original_file_path": "models/staging/stg_orders.sql",
+				"checksum":           map[string]any{"name": "sha256", "checksum": "outdated"},
+				"config":             map[string]any{"materialized": "incremental"},
+			},
+			"test.jaffle_shop.not_null_orders_order_id": map[string]any{
+				"name":               "not_null_orders_order_id",
+				"resource_type":      "test",
+				"package_name":       "jaffle_shop",
+				"original_file_path": "models/staging/schema.yml",
+				"depends_on":         map[string]any{"nodes": []string{"model.jaffle_shop.orders"}},
+			},
+			"seed.jaffle_shop.raw_customers": map[string]any{
+				"name":               "raw_customers",
+				"resource_type":      "seed",
+				"package_name":       "jaffle_shop",
+				"original_file_path": "seeds/raw_customers.csv",
+				"checksum":           map[string]any{"name": "sha256", "checksum": hex.EncodeToString(customersSum[:])},
+				"config":             map[string]any{"materialized": "seed", "delimiter": ","},
+			},
+			"seed.jaffle_shop.raw_orders": map[string]any{
+				"name":               "raw_orders",
+				"resource_type":      "seed",
+				"package_name":       "jaffle_shop",
+				"original_file_path": "seeds/raw_orders.csv",
+				"checksum":           map[string]any{"name": "path", "checksum": "seeds/raw_orders.csv"},
+				"config":             map[string]any{"materialized": "seed", "delimiter": ","},
+			},
+			"model.jaffle_shop.deleted": map[string]any{
+				"name":               "deleted",
+				"resource_type":      "model",
+				"package_name":       "jaffle_shop",
+				"original_file_path": "models/deleted.sql",
+			},
+		},
+		"macros": map[string]any{
+			"macro.dbt_utils.get_relations": map[string]any{
+				"name":               "get_relations",
+				"package_name":       "dbt_utils",
+				"original_file_path": "macros/sql/get_relations.sql",
+			},
+		},
+		"exposures": map[string]any{
+			"exposure.jaffle_shop.weekly_orders": map[string]any{
+				"name":               "weekly_orders",
+				"package_name":       "jaffle_shop",
+				"original_file_path": "models/exposures.yml",
+				"depends_on":         map[string]any{"nodes": []string{"model.jaffle_shop.orders"}},
+			},
+		},
+		"child_map": map[string]any{"model.jaffle_shop.orders": []string{"exposure.jaffle_shop.weekly_orders"}},
+	}
+
+	content, _ := json.Marshal(compiled)
+	os.MkdirAll(filepath.Join(root, "build"), 0755)
+	os.WriteFile(filepath.Join(root, "build", "manifest.json"), content, 0644)
+
+	settings, _ := LoadSettings(root)
+	manifest, err := settings.BuildManifest()
+	if err != nil {
+		t.Fatalf("could not build manifest %v", err)
+	}
+
+	node := manifest.Nodes["model.jaffle_shop.orders"]
+	if node.Config["materialized"] != "incremental" || node.Columns["order_id"].DataType != "integer" {
+		t.Errorf("an unchanged model should come from the compiled manifest, got %+v", node)
+	}
+	if node.OriginalPath != "file://"+filepath.Join(root, "models", "orders.sql") || node.CompiledPath != "file://"+filepath.Join(root, "build", "compiled", "jaffle_shop", "models", "orders.sql") {
+		t.Errorf("expected absolute paths but got %v %v", node.OriginalPath, node.CompiledPath)
+	}
+	if node.Range.End.Line != 4 {
+		t.Errorf("the location of the file should be kept, got %v", node.Range)
+	}
+
+	stgOrders := manifest.Nodes["model.jaffle_shop.stg_orders"]
+	if stgOrders.Config["materialized"] != "table" || stgOrders.SchemaPath == "" {
+		t.Errorf("a changed model should come from the files, got %+v", stgOrders)
+	}
+
+	for _, key := range []string{"seed.jaffle_shop.raw_customers", "seed.jaffle_shop.raw_orders"} {
+		if seed := manifest.Nodes[key]; seed.Config["delimiter"] != "," {
+			t.Errorf("an unchanged seed should come from the compiled manifest, got %+v", seed)
+		}
+	}
+
+	if _, ok := manifest.Nodes["model.jaffle_shop.deleted"]; ok {
+		t.Errorf("a model whose file is gone should be dropped")
+	}
+	if _, ok := manifest.Nodes["test.jaffle_shop.not_null_orders_order_id"]; ok {
+		t.Errorf("tests should not be merged as nodes")
+	}
+
+	macro, ok := manifest.Macros["macro.dbt_utils.get_relations"]
+	if !ok || macro.OriginalPath != "file://"+filepath.Join(root, "dbt_packages", "dbt_utils", "macros", "sql", "get_relations.sql") {
+		t.Errorf("expected the compiled package macro but got %+v", macro)
+	}
+	if _, ok := manifest.Macros["macro.dbt_utils.star"]; !ok {
+		t.Errorf("predicted macros should be kept")
+	}
+
+	exposure := manifest.Exposures["exposure.jaffle_shop.weekly_orders"]
+	if exposure.OriginalPath != "file://"+filepath.Join(root, "models", "exposures.yml") || len(manifest.ChildMap["model.jaffle_shop.orders"]) != 1 {
+		t.Errorf("expected the compiled exposures and lineage but got %+v %v", exposure, manifest.ChildMap)
+	}
+}
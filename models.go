@@ -1,14 +1,16 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/tliron/commonlog"
+	protocol "github.com/tliron/glsp/protocol_3_16"
 	"gopkg.in/yaml.v3"
 )
 
@@ -19,20 +21,134 @@ type ProjectSettings struct {
 	PathSettings pathSettings
 }
 
+// pathSettings is the dbt_project.yml file
 type pathSettings struct {
-	Name      string   `yaml:"name"`
-	ModelPath []string `yaml:"model-paths"`
-	MacroPath []string `yaml:"macro-paths"`
+	Name         string   `yaml:"name"`
+	ModelPath    []string `yaml:"model-paths"`
+	MacroPath    []string `yaml:"macro-paths"`
+	SeedPath     []string `yaml:"seed-paths"`
+	SnapshotPath []string `yaml:"snapshot-paths"`
+	TestPath     []string `yaml:"test-paths"`
+	AnalysisPath []string `yaml:"analysis-paths"`
+	DocsPath     []string `yaml:"docs-paths"`
+	TargetPath   string   `yaml:"target-path"`
+
+	PackagesInstallPath string `yaml:"packages-install-path"`
+
+	// Vars holds global vars and vars scoped to a package by its name
+	Vars map[string]any `yaml:"vars"`
+	// Models is the hierarchical models: config, keyed by package then directory
+	Models map[string]any `yaml:"models"`
+}
+
+// applyDefaults fills in what dbt assumes when a setting is left out
+func (ps *pathSettings) applyDefaults() {
+	defaultPaths := func(paths *[]string, value string) {
+		if len(*paths) == 0 {
+			*paths = []string{value}
+		}
+	}
+
+	defaultPaths(&ps.ModelPath, "models")
+	defaultPaths(&ps.MacroPath, "macros")
+	defaultPaths(&ps.SeedPath, "seeds")
+	defaultPaths(&ps.SnapshotPath, "snapshots")
+	defaultPaths(&ps.TestPath, "tests")
+	defaultPaths(&ps.AnalysisPath, "analyses")
+
+	// docs blocks can live anywhere dbt reads files from
+	if len(ps.DocsPath) == 0 {
+		for _, paths := range [][]string{ps.ModelPath, ps.SeedPath, ps.TestPath, ps.AnalysisPath, ps.MacroPath, ps.SnapshotPath} {
+			ps.DocsPath = append(ps.DocsPath, paths...)
+		}
+	}
+
+	if ps.TargetPath == "" {
+		ps.TargetPath = "target"
+	}
+	if ps.PackagesInstallPath == "" {
+		ps.PackagesInstallPath = "dbt_packages"
+	}
+	if ps.Vars == nil {
+		ps.Vars = map[string]any{}
+	}
+	if ps.Models == nil {
+		ps.Models = map[string]any{}
+	}
 }
 
 type ModelReference struct {
 	ModelName string
-	Range     Range
+	// Package is only set for the two argument form ref('package', 'model')
+	Package string
+	Range   Range
+	// NameRange covers the model name inside the quotes
+	NameRange Range
+}
+
+// Key returns the manifest key of the model being referenced
+func (r ModelReference) Key(projectName string) string {
+	if r.Package != "" {
+		projectName = r.Package
+	}
+	return fmt.Sprintf("model.%s.%s", projectName, r.ModelName)
+}
+
+type SourceReference struct {
+	SourceName string
+	TableName  string
+	Range      Range
+	// SourceRange and TableRange cover the names inside the quotes
+	SourceRange Range
+	TableRange  Range
 }
 
 type MacroReference struct {
 	ModelName string
 	Range     Range
+	// Package is set for namespaced calls like dbt_utils.star()
+	Package string
+	// NameRange covers the macro name, for calls it includes the package
+	NameRange Range
+	// Arguments is only set for macro definitions
+	Arguments []MacroArgument
+}
+
+// QualifiedName returns the name the macro was called with, e.g. dbt_utils.star
+func (r MacroReference) QualifiedName() string {
+	if r.Package != "" {
+		return r.Package + "." + r.ModelName
+	}
+	return r.ModelName
+}
+
+type VarReference struct {
+	Name string
+	// Default is the second argument of var() as written, it's only set when
+	// HasDefault is true
+	Default    string
+	HasDefault bool
+	Range      Range
+	// NameRange covers the var name inside the quotes
+	NameRange Range
+}
+
+// ConfigReference is a {{ config(...) }} call, only keyword arguments are configs
+type ConfigReference struct {
+	Range     Range
+	Arguments []ConfigArgument
+}
+
+type ConfigArgument struct {
+	Key      string
+	KeyRange Range
+	// Value is the argument decoded into the same types yaml would give us, it's
+	// only set when IsLiteral is true
+	Value     any
+	IsLiteral bool
+	// Expression is the argument as written
+	Expression string
+	ValueRange Range
 }
 
 type Range struct {
@@ -41,34 +157,150 @@ type Range struct {
 }
 
 type schemaModel struct {
-	ModelInformation []struct {
-		Name        string `yaml:"name"`
-		Description string `yaml:"description"`
-		Columns     []struct {
-			Name        string `yaml:"name"`
-			Description string `yaml:"description"`
-		} `yaml:"columns"`
-	} `yaml:"models"`
+	ModelInformation []schemaModelInformation `yaml:"models"`
+	Sources          []schemaSource           `yaml:"sources"`
 }
 
-func (m *schemaModel) ToNode() []Node {
+type schemaModelInformation struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Columns     []schemaColumn `yaml:"columns"`
+
+	// Range spans the model's entry in the yaml file, NameRange its name
+	Range     protocol.Range `yaml:"-"`
+	NameRange protocol.Range `yaml:"-"`
+}
+
+func (i *schemaModelInformation) UnmarshalYAML(value *yaml.Node) error {
+	type plain schemaModelInformation
+	if err := value.Decode((*plain)(i)); err != nil {
+		return err
+	}
+
+	i.Range = getYamlNodeRange(value)
+	if name := getYamlMappingValue(value, "name"); name != nil {
+		i.NameRange = getYamlValueRange(name)
+	}
+	return nil
+}
+
+type schemaColumn struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	DataType    string `yaml:"data_type"`
+
+	// Range spans the column's entry in the yaml file, NameRange its name
+	Range     protocol.Range `yaml:"-"`
+	NameRange protocol.Range `yaml:"-"`
+}
+
+func (c *schemaColumn) UnmarshalYAML(value *yaml.Node) error {
+	type plain schemaColumn
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	c.Range = getYamlNodeRange(value)
+	if name := getYamlMappingValue(value, "name"); name != nil {
+		c.NameRange = getYamlValueRange(name)
+	}
+	return nil
+}
+
+func (c schemaColumn) ToNodeColumn() NodeColumn {
+	return NodeColumn{
+		Name:        c.Name,
+		Description: c.Description,
+		DataType:    c.DataType,
+		Range:       c.Range,
+		NameRange:   c.NameRange,
+	}
+}
+
+type schemaSource struct {
+	Name        string              `yaml:"name"`
+	Description string              `yaml:"description"`
+	Loader      string              `yaml:"loader"`
+	Tables      []schemaSourceTable `yaml:"tables"`
+}
+
+type schemaSourceTable struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Loader      string         `yaml:"loader"`
+	Columns     []schemaColumn `yaml:"columns"`
+
+	// Range spans the table's entry in the yaml file, NameRange its name
+	Range     protocol.Range `yaml:"-"`
+	NameRange protocol.Range `yaml:"-"`
+}
+
+func (t *schemaSourceTable) UnmarshalYAML(value *yaml.Node) error {
+	type plain schemaSourceTable
+	if err := value.Decode((*plain)(t)); err != nil {
+		return err
+	}
+
+	t.Range = getYamlNodeRange(value)
+	if name := getYamlMappingValue(value, "name"); name != nil {
+		t.NameRange = getYamlValueRange(name)
+	}
+	return nil
+}
+
+func (m *schemaModel) ToNode(path string) []Node {
 	node := []Node{}
 	for _, info := range m.ModelInformation {
 
 		columns := map[string]NodeColumn{}
 		for _, column := range info.Columns {
-			columns[column.Name] = NodeColumn(column)
+			columns[column.Name] = column.ToNodeColumn()
 		}
 
 		node = append(node, Node{
-			Name:        info.Name,
-			Description: info.Description,
-			Columns:     columns,
+			Name:            info.Name,
+			Description:     info.Description,
+			Columns:         columns,
+			SchemaPath:      fmt.Sprintf("file://%v", path),
+			SchemaRange:     info.Range,
+			SchemaNameRange: info.NameRange,
 		})
 	}
 	return node
 }
 
+func (m *schemaModel) ToSources(projectName, path string) map[string]Source {
+	sources := map[string]Source{}
+	for _, source := range m.Sources {
+		for _, table := range source.Tables {
+
+			columns := map[string]NodeColumn{}
+			for _, column := range table.Columns {
+				columns[column.Name] = column.ToNodeColumn()
+			}
+
+			loader := table.Loader
+			if loader == "" {
+				loader = source.Loader
+			}
+
+			key := fmt.Sprintf("source.%s.%s.%s", projectName, source.Name, table.Name)
+			sources[key] = Source{
+				Name:              table.Name,
+				SourceName:        source.Name,
+				Description:       table.Description,
+				SourceDescription: source.Description,
+				Loader:            loader,
+				OriginalPath:      fmt.Sprintf("file://%v", path),
+				Columns:           columns,
+				Range:             table.Range,
+				NameRange:         table.NameRange,
+			}
+		}
+	}
+	return sources
+}
+
 func LoadSettings(workspaceFolder string) (ProjectSettings, error) {
 	cleanedWorkspaceUri, err := CleanUri(workspaceFolder)
 	if err != nil {
@@ -85,82 +317,284 @@ func LoadSettings(workspaceFolder string) (ProjectSettings, error) {
 	if err != nil {
 		return ProjectSettings{}, err
 	}
+
+	settings.applyDefaults()
 	return ProjectSettings{
 		Name:         settings.Name,
 		RootPath:     cleanedWorkspaceUri,
 		PathSettings: settings,
-		TargetPath:   filepath.Join(cleanedWorkspaceUri, "target"),
+		TargetPath:   filepath.Join(cleanedWorkspaceUri, settings.TargetPath),
 	}, nil
 }
 
+// modelConfigDictionaries are configs whose value is a map, any other key without
+// a + in front that holds a map is a directory
+var modelConfigDictionaries = []string{"meta", "docs", "persist_docs", "grants", "contract", "labels", "column_types"}
+
+// GetModelConfig resolves the models: config for a model of the package, the
+// directories it sits in are relative to its model path. More specific levels
+// override the ones above them, except tags which add up
+func (settings ProjectSettings) GetModelConfig(packageName string, directories []string, modelName string) map[string]any {
+	config := map[string]any{}
+
+	level, ok := settings.PathSettings.Models[packageName].(map[string]any)
+	for _, name := range append(append([]string{}, directories...), modelName) {
+		if !ok {
+			break
+		}
+		mergeModelConfig(config, level)
+		level, ok = level[name].(map[string]any)
+	}
+	if ok {
+		mergeModelConfig(config, level)
+	}
+
+	return config
+}
+
+// GetModelConfigForFile resolves the models: config for a model file of the
+// package, it's empty when the file is outside of the model paths
+func (settings ProjectSettings) GetModelConfigForFile(packageName, path string) map[string]any {
+	modelName := strings.TrimSuffix(filepath.Base(path), ".sql")
+
+	for _, modelPath := range settings.PathSettings.ModelPath {
+		relativePath, err := filepath.Rel(filepath.Join(settings.GetRootDirectory(), modelPath), filepath.Dir(path))
+		if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, "../") {
+			continue
+		}
+
+		directories := []string{}
+		if relativePath != "." {
+			directories = strings.Split(filepath.ToSlash(relativePath), "/")
+		}
+		return settings.GetModelConfig(packageName, directories, modelName)
+	}
+
+	return map[string]any{}
+}
+
+func mergeModelConfig(config map[string]any, level map[string]any) {
+	for key, value := range level {
+		name, isConfig := strings.CutPrefix(key, "+")
+		if _, isMap := value.(map[string]any); isMap && !isConfig && !slices.Contains(modelConfigDictionaries, name) {
+			continue
+		}
+
+		if name == "tags" {
+			config[name] = append(toStringList(config[name]), toStringList(value)...)
+			continue
+		}
+		config[name] = value
+	}
+}
+
+func toStringList(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		list := []string{}
+		for _, item := range v {
+			list = append(list, fmt.Sprint(item))
+		}
+		return list
+	}
+	return []string{}
+}
+
 func (ps ProjectSettings) GetRootDirectory() string {
 	return ps.RootPath
 }
 
-func (settings ProjectSettings) GetSchemaFiles() (map[string]Node, error) {
-	logger := commonlog.GetLoggerf("%s.schema", "settings")
-	schemaFiles := map[string]Node{}
+// projectFiles are the yaml files dbt reads for configuration rather than properties
+var projectFiles = []string{"dbt_project.yml", "packages.yml", "dependencies.yml", "selectors.yml", "profiles.yml"}
+
+func isProjectDirectory(path string) bool {
+	_, err := os.Stat(filepath.Join(path, "dbt_project.yml"))
+	return err == nil
+}
+
+func (settings ProjectSettings) GetSchemaFilePaths() ([]string, error) {
 	yamlRegex := regexp.MustCompile(`\.yml|\.yaml`)
+	paths := []string{}
 
-	for _, path := range settings.PathSettings.ModelPath {
+	directories := [][]string{settings.PathSettings.ModelPath, settings.PathSettings.SeedPath, settings.PathSettings.SnapshotPath, settings.PathSettings.AnalysisPath, settings.PathSettings.MacroPath}
+	for _, path := range slices.Concat(directories...) {
 		modelPath := filepath.Join(settings.GetRootDirectory(), path)
 
 		err := filepath.Walk(modelPath, func(path string, info fs.FileInfo, error error) error {
+			if error != nil {
+				return nil
+			}
+
+			// installed packages and the project file itself are not property files
 			if info.IsDir() {
+				if path != modelPath && isProjectDirectory(path) {
+					return filepath.SkipDir
+				}
 				return nil
 			}
 
 			extension := filepath.Ext(info.Name())
-			if !yamlRegex.MatchString(extension) {
+			if !yamlRegex.MatchString(extension) || slices.Contains(projectFiles, info.Name()) {
 				return nil
 			}
 
-			fileContent, err := ReadFileUri(path)
-			logger.Infof("file : %v", path)
-			if err != nil {
+			paths = append(paths, path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-				logger.Infof("Could not read file: %v", err)
-				return err
-			}
+	return paths, nil
+}
 
-			model := schemaModel{}
-			err = yaml.Unmarshal(fileContent, &model)
+func (settings ProjectSettings) GetSchemaFiles() (map[string]Node, error) {
+	logger := commonlog.GetLoggerf("%s.schema", "settings")
+	schemaFiles := map[string]Node{}
 
-			logger.Infof("file : %v", model)
-			if err != nil {
-				logger.Infof("Could not parse yaml file %v , file : %v", err, path)
-				return err
-			}
+	paths, err := settings.GetSchemaFilePaths()
+	if err != nil {
+		return nil, err
+	}
 
-			for _, node := range model.ToNode() {
-				schemaFiles[node.Name] = node
-			}
+	for _, path := range paths {
+		fileContent, err := ReadFileUri(path)
+		logger.Infof("file : %v", path)
+		if err != nil {
+			logger.Infof("Could not read file: %v", err)
+			continue
+		}
 
-			return nil
-		})
+		// one broken file shouldn't hide what every other schema file documents
+		model := schemaModel{}
+		err = yaml.Unmarshal(fileContent, &model)
+
+		logger.Infof("file : %v", model)
 		if err != nil {
-			return nil, err
+			logger.Infof("Could not parse yaml file %v , file : %v", err, path)
+			continue
+		}
+
+		for _, node := range model.ToNode(path) {
+			schemaFiles[node.Name] = node
 		}
 	}
 
 	return schemaFiles, nil
 }
 
+func (settings ProjectSettings) GetSources() (map[string]Source, error) {
+	logger := commonlog.GetLoggerf("%s.sources", "settings")
+	sources := map[string]Source{}
+
+	paths, err := settings.GetSchemaFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		fileContent, err := ReadFileUri(path)
+		if err != nil {
+			logger.Infof("Could not read file: %v", err)
+			continue
+		}
+
+		model := schemaModel{}
+		if err = yaml.Unmarshal(fileContent, &model); err != nil {
+			logger.Infof("Could not parse yaml file %v , file : %v", err, path)
+			continue
+		}
+
+		for key, source := range model.ToSources(settings.Name, path) {
+			sources[key] = source
+		}
+	}
+
+	return sources, nil
+}
+
+// getSchemaModelNameRanges finds the `- name:` value of every entry under `models:`
+// that matches the model name
+func getSchemaModelNameRanges(content []byte, modelName string) []protocol.Range {
+	ranges := []protocol.Range{}
+
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(content, &document); err != nil || len(document.Content) == 0 {
+		return ranges
+	}
+
+	root := document.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "models" {
+			continue
+		}
+
+		for _, model := range root.Content[i+1].Content {
+			for j := 0; j+1 < len(model.Content); j += 2 {
+				key, value := model.Content[j], model.Content[j+1]
+				if key.Value != "name" || value.Value != modelName {
+					continue
+				}
+				ranges = append(ranges, getYamlValueRange(value))
+			}
+		}
+	}
+
+	return ranges
+}
+
+func getYamlValueRange(value *yaml.Node) protocol.Range {
+	start := value.Column - 1
+	if value.Style == yaml.SingleQuotedStyle || value.Style == yaml.DoubleQuotedStyle {
+		start += 1
+	}
+
+	startPosition := protocol.Position{Line: uint32(value.Line - 1), Character: uint32(start)}
+
+	// the text of a block scalar starts on the line after its | or > and we don't
+	// know how far it is indented, so it ends at the start of the line after its
+	// last one. The lines a > scalar folded into one can't be counted
+	if value.Style == yaml.LiteralStyle || value.Style == yaml.FoldedStyle {
+		lines := strings.Count(strings.TrimRight(value.Value, "\n"), "\n") + 1
+		return protocol.Range{
+			Start: startPosition,
+			End:   protocol.Position{Line: startPosition.Line + uint32(lines) + 1, Character: 0},
+		}
+	}
+
+	return protocol.Range{
+		Start: startPosition,
+		End:   protocol.Position{Line: startPosition.Line, Character: uint32(start + len(value.Value))},
+	}
+}
+
 func (settings ProjectSettings) PredictManifestFile(projectName string, schemas map[string]Node) (Manifest, error) {
 	logger := commonlog.GetLogger("models.PredictManifestFile")
-	parser := NewJinjaParser()
 
 	manifest := Manifest{
-		Nodes:    map[string]Node{},
-		Macros:   map[string]Macro{},
-		Metadata: Metadata{ProjectName: projectName},
+		Nodes:      map[string]Node{},
+		Macros:     map[string]Macro{},
+		Metadata:   Metadata{ProjectName: projectName},
+		References: map[string][]ReferenceLocation{},
 	}
 
 	for _, path := range settings.PathSettings.ModelPath {
 		modelPath := filepath.Join(settings.GetRootDirectory(), path)
 
 		filepath.Walk(modelPath, func(path string, info fs.FileInfo, error error) error {
+			if error != nil {
+				return nil
+			}
+
 			if info.IsDir() {
+				if path != modelPath && isProjectDirectory(path) {
+					return filepath.SkipDir
+				}
 				return nil
 			}
 
@@ -175,47 +609,268 @@ func (settings ProjectSettings) PredictManifestFile(projectName string, schemas
 				return err
 			}
 
-			fileName := strings.ReplaceAll(info.Name(), ".sql", "")
-
-			key := fmt.Sprintf("model.%v.%v", projectName, fileName)
-			logger.Infof("Adding key %v with filename %v", key, fileName)
-			schema, schemaExists := schemas[fileName]
-
-			var node Node
-			if schemaExists {
-				node = schema
-				node.OriginalPath = fmt.Sprintf("file://%v", path)
-			} else {
-				node = Node{
-					Name:         fileName,
-					RawCode:      string(fileContent),
-					Columns:      map[string]NodeColumn{},
-					OriginalPath: fmt.Sprintf("file://%v", path),
-				}
+			settings.indexModel(&manifest, projectName, path, string(fileContent), schemas)
+			return nil
+		})
+	}
+
+	for key, macro := range settings.getMacros(projectName) {
+		manifest.Macros[key] = macro
+	}
+
+	for _, dependency := range settings.GetPackages() {
+		for key, macro := range dependency.getMacros(dependency.Name) {
+			manifest.Macros[key] = macro
+		}
+	}
+
+	for _, path := range settings.PathSettings.SeedPath {
+		seedPath := filepath.Join(settings.GetRootDirectory(), path)
+
+		filepath.Walk(seedPath, func(path string, info fs.FileInfo, error error) error {
+			if error != nil || info.IsDir() || filepath.Ext(info.Name()) != `.csv` {
+				return nil
 			}
 
-			fileString := string(fileContent)
+			seedName := strings.TrimSuffix(info.Name(), ".csv")
+			node, schemaExists := schemas[seedName]
+			if !schemaExists {
+				node = Node{Name: seedName, Columns: map[string]NodeColumn{}}
+			}
+			node.OriginalPath = fmt.Sprintf("file://%v", path)
+			node.ResourceType = "seed"
+
+			manifest.Nodes[fmt.Sprintf("seed.%v.%v", projectName, seedName)] = node
+			return nil
+		})
+	}
+
+	for _, path := range settings.PathSettings.SnapshotPath {
+		snapshotPath := filepath.Join(settings.GetRootDirectory(), path)
 
-			if !parser.HasJinjaBlocks(fileString) {
-				manifest.Nodes[key] = node
+		filepath.Walk(snapshotPath, func(path string, info fs.FileInfo, error error) error {
+			if error != nil || info.IsDir() || filepath.Ext(info.Name()) != `.sql` {
 				return nil
 			}
 
-			for _, ref := range parser.GetAllRefTags(fileString) {
-				key := fmt.Sprintf("model.%v.%v", projectName, ref.ModelName)
-				node.Depends.Nodes = append(node.Depends.Nodes, key)
+			fileContent, err := ReadFileUri(path)
+			if err != nil {
+				logger.Infof("Could not read file: %v, path: %v", err, path)
+				return err
 			}
 
-			manifest.Nodes[key] = node
+			indexSnapshots(&manifest, projectName, path, string(fileContent))
 			return nil
 		})
 	}
 
+	return manifest, nil
+}
+
+// BuildManifest indexes the whole project and merges in the manifest dbt compiled
+// when there is one, schema files and the compiled manifest are optional
+func (settings ProjectSettings) BuildManifest() (Manifest, error) {
+	logger := commonlog.GetLogger("models.BuildManifest")
+
+	schemas, err := settings.GetSchemaFiles()
+	if err != nil {
+		logger.Errorf("Could not load schema files %v", err)
+	}
+
+	manifest, err := settings.PredictManifestFile(settings.Name, schemas)
+	if err != nil {
+		return manifest, err
+	}
+
+	manifest.Sources, err = settings.GetSources()
+	if err != nil {
+		logger.Errorf("Could not load sources %v", err)
+	}
+
+	manifest.Vars, err = settings.GetVars()
+	if err != nil {
+		logger.Errorf("Could not load vars %v", err)
+	}
+
+	compiled, err := settings.LoadManifestFile()
+	if err != nil {
+		logger.Infof("could not load manifest file %v", err)
+		return manifest, nil
+	}
+
+	return settings.mergeManifests(compiled, manifest), nil
+}
+
+// indexModel adds the model in the file at path to the manifest, along with the
+// refs it makes. The schema yaml entry of the model comes from schemas
+func (settings ProjectSettings) indexModel(manifest *Manifest, projectName, path, fileString string, schemas map[string]Node) {
+	logger := commonlog.GetLogger("models.indexModel")
+	parser := NewJinjaParser()
+
+	fileName := strings.TrimSuffix(filepath.Base(path), ".sql")
+
+	key := fmt.Sprintf("model.%v.%v", projectName, fileName)
+	logger.Infof("Adding key %v with filename %v", key, fileName)
+	schema, schemaExists := schemas[fileName]
+
+	var node Node
+	if schemaExists {
+		node = schema
+		node.OriginalPath = fmt.Sprintf("file://%v", path)
+	} else {
+		node = Node{
+			Name:         fileName,
+			RawCode:      fileString,
+			Columns:      map[string]NodeColumn{},
+			OriginalPath: fmt.Sprintf("file://%v", path),
+		}
+	}
+	node.ResourceType = "model"
+	node.Depends = Depends{}
+
+	node.Range = getRangeInFile(fileString, Range{Start: 0, End: len(fileString)})
+	node.Config = mergeConfigBlocks(settings.GetModelConfigForFile(projectName, path), parser.GetConfigBlocks(fileString))
+
+	if !parser.HasJinjaBlocks(fileString) {
+		manifest.Nodes[key] = node
+		return
+	}
+
+	for _, ref := range parser.GetAllRefTags(fileString) {
+		refKey := manifest.GetRefKey(ref)
+		node.Depends.Nodes = append(node.Depends.Nodes, refKey)
+		manifest.References[refKey] = append(manifest.References[refKey], ReferenceLocation{
+			NodeKey:   key,
+			FileUri:   node.OriginalPath,
+			Range:     getRangeInFile(fileString, ref.Range),
+			NameRange: getRangeInFile(fileString, ref.NameRange),
+		})
+	}
+
+	for _, source := range parser.GetAllSourceTags(fileString) {
+		sourceKey := fmt.Sprintf("source.%v.%v.%v", projectName, source.SourceName, source.TableName)
+		node.Depends.Nodes = append(node.Depends.Nodes, sourceKey)
+	}
+
+	manifest.Nodes[key] = node
+}
+
+// indexSnapshots adds every {% snapshot %} block in the file at path to the manifest
+func indexSnapshots(manifest *Manifest, projectName, path, fileString string) {
+	parser := NewJinjaParser()
+
+	for _, snapshot := range parser.GetSnapshotDefinitions(fileString) {
+		node := Node{
+			Name:         snapshot.ModelName,
+			RawCode:      fileString[snapshot.Range.Start:snapshot.Range.End],
+			Columns:      map[string]NodeColumn{},
+			OriginalPath: fmt.Sprintf("file://%v", path),
+			ResourceType: "snapshot",
+		}
+
+		for _, ref := range parser.GetAllRefTags(node.RawCode) {
+			node.Depends.Nodes = append(node.Depends.Nodes, manifest.GetRefKey(ref))
+		}
+		for _, source := range parser.GetAllSourceTags(node.RawCode) {
+			node.Depends.Nodes = append(node.Depends.Nodes, fmt.Sprintf("source.%v.%v.%v", projectName, source.SourceName, source.TableName))
+		}
+
+		manifest.Nodes[fmt.Sprintf("snapshot.%v.%v", projectName, snapshot.ModelName)] = node
+	}
+}
+
+// GetVars reads the vars: section of dbt_project.yml. Maps keyed by the project
+// or an installed package are the vars scoped to that package
+func (settings ProjectSettings) GetVars() ([]ProjectVar, error) {
+	vars := []ProjectVar{}
+
+	fileContent, err := ReadFileUri2(settings.GetRootDirectory(), "dbt_project.yml")
+	if err != nil {
+		return vars, err
+	}
+
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(fileContent, &document); err != nil || len(document.Content) == 0 {
+		return vars, err
+	}
+
+	scopes := []string{settings.Name}
+	for _, dependency := range settings.GetPackages() {
+		scopes = append(scopes, dependency.Name)
+	}
+
+	fileUri := fmt.Sprintf("file://%v", filepath.Join(settings.GetRootDirectory(), "dbt_project.yml"))
+	readVars := func(mapping *yaml.Node, scope string) {
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			key, value := mapping.Content[i], mapping.Content[i+1]
+			if scope == "" && value.Kind == yaml.MappingNode && slices.Contains(scopes, key.Value) {
+				continue
+			}
+
+			var decoded any
+			if err := value.Decode(&decoded); err != nil {
+				continue
+			}
+
+			vars = append(vars, ProjectVar{
+				Name:      key.Value,
+				Package:   scope,
+				Value:     decoded,
+				FileUri:   fileUri,
+				NameRange: getYamlValueRange(key),
+			})
+		}
+	}
+
+	varsNode := getYamlMappingValue(document.Content[0], "vars")
+	if varsNode == nil || varsNode.Kind != yaml.MappingNode {
+		return vars, nil
+	}
+
+	readVars(varsNode, "")
+	for _, scope := range scopes {
+		if scoped := getYamlMappingValue(varsNode, scope); scoped != nil && scoped.Kind == yaml.MappingNode {
+			readVars(scoped, scope)
+		}
+	}
+
+	return vars, nil
+}
+
+// GetPackages loads the settings of every package installed in the project
+func (settings ProjectSettings) GetPackages() []ProjectSettings {
+	logger := commonlog.GetLoggerf("%s.packages", "settings")
+	packages := []ProjectSettings{}
+
+	projectFiles, err := filepath.Glob(filepath.Join(settings.GetRootDirectory(), settings.PathSettings.PackagesInstallPath, "*", "dbt_project.yml"))
+	if err != nil {
+		logger.Infof("Could not look for packages %v", err)
+		return packages
+	}
+
+	for _, projectFile := range projectFiles {
+		dependency, err := LoadSettings(filepath.Dir(projectFile))
+		if err != nil {
+			logger.Infof("Could not load package %v, %v", projectFile, err)
+			continue
+		}
+		packages = append(packages, dependency)
+	}
+
+	return packages
+}
+
+// getMacros finds every macro defined in the project's macro paths, keyed by
+// macro.<projectName>.<name>
+func (settings ProjectSettings) getMacros(projectName string) map[string]Macro {
+	logger := commonlog.GetLogger("models.getMacros")
+	macros := map[string]Macro{}
+
 	for _, path := range settings.PathSettings.MacroPath {
 		macroPath := filepath.Join(settings.GetRootDirectory(), path)
 
 		filepath.Walk(macroPath, func(path string, info fs.FileInfo, error error) error {
-			if info.IsDir() {
+			if error != nil || info.IsDir() {
 				return nil
 			}
 
@@ -230,35 +885,45 @@ func (settings ProjectSettings) PredictManifestFile(projectName string, schemas
 				return err
 			}
 
-			fileString := string(fileContent)
-			if !parser.HasJinjaBlocks(fileString) {
-				return nil
-			}
-
-			for _, macro := range parser.GetMacroDefinitions(fileString) {
-				key := fmt.Sprintf("macro.%v.%v", projectName, macro.ModelName)
-				manifest.Macros[key] = Macro{
-					OriginalPath: fmt.Sprintf("file://%v", path),
-					Name:         macro.ModelName,
-				}
+			for key, macro := range getFileMacros(projectName, path, string(fileContent)) {
+				macros[key] = macro
 			}
 
 			return nil
 		})
 	}
 
-	return manifest, nil
+	return macros
+}
+
+// getFileMacros reads the macros defined in the file at path, keyed by
+// macro.<projectName>.<name>
+func getFileMacros(projectName, path, fileString string) map[string]Macro {
+	parser := NewJinjaParser()
+	macros := map[string]Macro{}
+
+	if !parser.HasJinjaBlocks(fileString) {
+		return macros
+	}
+
+	for _, macro := range parser.GetMacroDefinitions(fileString) {
+		key := fmt.Sprintf("macro.%v.%v", projectName, macro.ModelName)
+		macros[key] = Macro{
+			OriginalPath: fmt.Sprintf("file://%v", path),
+			Name:         macro.ModelName,
+			Range:        getRangeInFile(fileString, macro.Range),
+			NameRange:    getRangeInFile(fileString, macro.NameRange),
+			Arguments:    macro.Arguments,
+		}
+	}
+	return macros
 }
 
 func (settings ProjectSettings) LoadManifestFile() (Manifest, error) {
-	manifestPath := filepath.Join(settings.TargetPath, "manifest.json")
-	file, err := ReadFileUri2(manifestPath, "manifest.json")
+	file, err := ReadFileUri2(settings.TargetPath, "manifest.json")
 	if err != nil {
 		return Manifest{}, err
 	}
 
-	var manifest Manifest
-	err = json.Unmarshal(file, &manifest)
-
-	return manifest, err
+	return parseManifest(file)
 }
@@ -2,6 +2,9 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -20,3 +23,154 @@ func TestSchemaFile(t *testing.T) {
 		t.Errorf("expected 2 but got %v", modelCount)
 	}
 }
+
+func TestSourceYamlPositions(t *testing.T) {
+	fileContent, _ := os.ReadFile("./tests/sources.yml")
+	model := schemaModel{}
+	if err := yaml.Unmarshal(fileContent, &model); err != nil {
+		t.Fatalf("error %v", err)
+	}
+
+	sources := model.ToSources("test", "/project/models/sources.yml")
+	orders, ok := sources["source.test.raw.orders"]
+	if !ok {
+		t.Fatalf("could not find source.test.raw.orders in %v", sources)
+	}
+
+	if orders.NameRange.Start.Line != 7 || orders.NameRange.Start.Character != 14 || orders.Loader != "fivetran" {
+		t.Errorf("got wrong source %+v", orders)
+	}
+
+	if orders.Range.Start.Line != 7 || orders.Range.End.Line != 11 {
+		t.Errorf("got wrong source entry range %v", orders.Range)
+	}
+
+	if sources["source.test.raw.customers"].Loader != "stitch" {
+		t.Errorf("table loader should override the source loader")
+	}
+}
+
+func TestPackageMacros(t *testing.T) {
+	settings, err := LoadSettings("./tests/project")
+	if err != nil {
+		t.Fatalf("could not load settings %v", err)
+	}
+
+	manifest, err := settings.PredictManifestFile(settings.Name, map[string]Node{})
+	if err != nil {
+		t.Fatalf("could not predict manifest %v", err)
+	}
+
+	if _, ok := manifest.Macros["macro.jaffle_shop.cents_to_dollars"]; !ok {
+		t.Errorf("expected the project macro but got %v", manifest.Macros)
+	}
+
+	star, ok := manifest.Macros["macro.dbt_utils.star"]
+	if !ok {
+		t.Fatalf("expected the package macro but got %v", manifest.Macros)
+	}
+
+	if len(star.Arguments) != 6 || star.NameRange.Start.Character != 9 {
+		t.Errorf("got wrong package macro %+v", star)
+	}
+
+	if key, _, ok := manifest.FindMacro("dbt_utils.star"); !ok || key != "macro.dbt_utils.star" {
+		t.Errorf("could not find namespaced macro, got %v", key)
+	}
+}
+
+func TestProjectDefaults(t *testing.T) {
+	settings, err := LoadSettings("./tests/project")
+	if err != nil {
+		t.Fatalf("could not load settings %v", err)
+	}
+
+	paths := settings.PathSettings
+	if paths.SeedPath[0] != "seeds" || paths.SnapshotPath[0] != "snapshots" || paths.AnalysisPath[0] != "analyses" || paths.PackagesInstallPath != "dbt_packages" {
+		t.Errorf("expected dbt defaults but got %+v", paths)
+	}
+
+	if len(paths.DocsPath) != 6 || !strings.HasSuffix(settings.TargetPath, "build") {
+		t.Errorf("got wrong docs or target path %v %v", paths.DocsPath, settings.TargetPath)
+	}
+
+	if paths.Vars["start_date"] != "2020-01-01" {
+		t.Errorf("got wrong vars %v", paths.Vars)
+	}
+
+	packages := settings.GetPackages()
+	if len(packages) != 1 || packages[0].Name != "dbt_utils" || packages[0].PathSettings.ModelPath[0] != "models" {
+		t.Errorf("expected package defaults but got %+v", packages)
+	}
+}
+
+func TestModelConfig(t *testing.T) {
+	settings, _ := LoadSettings("./tests/project")
+
+	config := settings.GetModelConfig("jaffle_shop", []string{"staging"}, "stg_orders")
+	if config["materialized"] != "table" || config["enabled"] != true {
+		t.Errorf("got wrong config %v", config)
+	}
+
+	if tags := config["tags"].([]string); !slices.Equal(tags, []string{"nightly", "staging"}) {
+		t.Errorf("expected tags to add up but got %v", tags)
+	}
+
+	if _, ok := config["stg_orders"]; ok {
+		t.Errorf("directories should not end up in the config %v", config)
+	}
+
+	if config := settings.GetModelConfig("jaffle_shop", []string{}, "orders"); config["materialized"] != "view" {
+		t.Errorf("got wrong config for orders %v", config)
+	}
+
+	manifest, _ := settings.PredictManifestFile(settings.Name, map[string]Node{})
+	if node := manifest.Nodes["model.jaffle_shop.stg_orders"]; node.Config["materialized"] != "table" {
+		t.Errorf("expected the config on the node but got %v", node.Config)
+	}
+}
+
+func TestProjectVars(t *testing.T) {
+	settings, _ := LoadSettings("./tests/project")
+
+	vars, err := settings.GetVars()
+	if err != nil {
+		t.Fatalf("could not load vars %v", err)
+	}
+	manifest := Manifest{Metadata: Metadata{ProjectName: "jaffle_shop"}, Vars: vars}
+
+	startDate, ok := manifest.FindVar("jaffle_shop", "start_date")
+	if !ok || startDate.Package != "" || startDate.ValueString() != "2020-01-01" || startDate.NameRange.Start.Line != 8 {
+		t.Errorf("got wrong global var %+v", startDate)
+	}
+
+	methods, ok := manifest.FindVar("jaffle_shop", "payment_methods")
+	if !ok || methods.Package != "jaffle_shop" || !strings.HasSuffix(methods.FileUri, "dbt_project.yml") {
+		t.Errorf("got wrong scoped var %+v", methods)
+	}
+
+	if _, ok := manifest.FindVar("dbt_utils", "payment_methods"); ok {
+		t.Errorf("payment_methods is scoped to jaffle_shop")
+	}
+
+	if _, ok := manifest.FindVar("jaffle_shop", "jaffle_shop"); ok {
+		t.Errorf("the project scope is not a var")
+	}
+}
+
+func TestBrokenSchemaFile(t *testing.T) {
+	root := copyProject(t, "./tests/project")
+	os.WriteFile(filepath.Join(root, "models", "broken.yml"), []byte("models: [\n  - name: :"), 0644)
+	os.WriteFile(filepath.Join(root, "models", "sources.yml"), []byte("sources:\n  - name: raw\n    tables:\n      - name: orders\n"), 0644)
+
+	settings, _ := LoadSettings(root)
+	schemas, err := settings.GetSchemaFiles()
+	if _, ok := schemas["stg_orders"]; err != nil || !ok {
+		t.Errorf("a broken schema file should not hide the others, got %v %v", schemas, err)
+	}
+
+	sources, err := settings.GetSources()
+	if _, ok := sources["source.jaffle_shop.raw.orders"]; err != nil || !ok {
+		t.Errorf("a broken schema file should not hide the sources, got %v %v", sources, err)
+	}
+}
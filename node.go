@@ -2,39 +2,383 @@ package main
 
 import (
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/tliron/commonlog"
 	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
 )
 
 type Manifest struct {
-	Nodes    map[string]Node  `json:"nodes"`
-	Macros   map[string]Macro `json:"macros"`
-	Metadata Metadata         `json:"metadata"`
+	Nodes          map[string]Node          `json:"nodes"`
+	Macros         map[string]Macro         `json:"macros"`
+	Sources        map[string]Source        `json:"sources"`
+	Exposures      map[string]Exposure      `json:"exposures"`
+	Metrics        map[string]Metric        `json:"metrics"`
+	SemanticModels map[string]SemanticModel `json:"semantic_models"`
+	Docs           map[string]Doc           `json:"docs"`
+	Groups         map[string]Group         `json:"groups"`
+	Selectors      map[string]Selector      `json:"selectors"`
+	// ParentMap and ChildMap are the lineage dbt worked out when it compiled the
+	// project, we don't predict them
+	ParentMap map[string][]string `json:"parent_map"`
+	ChildMap  map[string][]string `json:"child_map"`
+	Metadata  Metadata            `json:"metadata"`
+
+	// References maps a model key to every ref() that points at it
+	References map[string][]ReferenceLocation `json:"-"`
+	// Vars are the vars defined in dbt_project.yml
+	Vars []ProjectVar `json:"-"`
+}
+
+// Clone copies the manifest so that it can be changed without affecting the
+// original, nodes are replaced rather than changed in place so they are shared.
+// What only comes from the compiled manifest, like exposures and the lineage
+// maps, is never changed after loading and is shared too
+func (m Manifest) Clone() Manifest {
+	clone := m
+	clone.Nodes = make(map[string]Node, len(m.Nodes))
+	for key, node := range m.Nodes {
+		clone.Nodes[key] = node
+	}
+
+	clone.Macros = make(map[string]Macro, len(m.Macros))
+	for key, macro := range m.Macros {
+		clone.Macros[key] = macro
+	}
+
+	clone.Sources = make(map[string]Source, len(m.Sources))
+	for key, source := range m.Sources {
+		clone.Sources[key] = source
+	}
+
+	clone.References = make(map[string][]ReferenceLocation, len(m.References))
+	for key, references := range m.References {
+		clone.References[key] = slices.Clone(references)
+	}
+
+	clone.Vars = slices.Clone(m.Vars)
+	return clone
+}
+
+type ProjectVar struct {
+	Name string
+	// Package is set when the var is scoped to a package, empty for global vars
+	Package   string
+	Value     any
+	FileUri   string
+	NameRange protocol.Range
+}
+
+type ReferenceLocation struct {
+	// NodeKey is the model the ref() was found in
+	NodeKey   string
+	FileUri   string
+	Range     protocol.Range
+	NameRange protocol.Range
 }
 
 type Metadata struct {
-	ProjectName string `json:"project_name"`
+	ProjectName      string `json:"project_name"`
+	DbtSchemaVersion string `json:"dbt_schema_version"`
+	DbtVersion       string `json:"dbt_version"`
+	GeneratedAt      string `json:"generated_at"`
 }
 
 type Node struct {
 	Columns      map[string]NodeColumn
-	Name         string  `json:"name"`
-	Description  string  `json:"description"`
-	OriginalPath string  `json:"original_file_path"`
-	RawCode      string  `json:"raw_code"`
-	Depends      Depends `json:"depends_on"`
+	Name         string         `json:"name"`
+	Description  string         `json:"description"`
+	OriginalPath string         `json:"original_file_path"`
+	RawCode      string         `json:"raw_code"`
+	Depends      Depends        `json:"depends_on"`
+	ResourceType string         `json:"resource_type"`
+	Config       map[string]any `json:"config"`
+	PackageName  string         `json:"package_name"`
+	Checksum     Checksum       `json:"checksum"`
+	CompiledPath string         `json:"compiled_path"`
+	PatchPath    string         `json:"patch_path"`
+	Tags         []string       `json:"tags"`
+	Fqn          []string       `json:"fqn"`
+
+	// Range spans the whole sql file of the model
+	Range protocol.Range `json:"-"`
+	// SchemaPath is the yaml file that documents the model, SchemaRange spans its
+	// entry there and SchemaNameRange its `name:` value
+	SchemaPath      string         `json:"-"`
+	SchemaRange     protocol.Range `json:"-"`
+	SchemaNameRange protocol.Range `json:"-"`
+}
+
+func (n Node) GetHoverText(key string) string {
+	text := fmt.Sprintf("**%s**", n.Name)
+	if materialized, ok := n.Config["materialized"].(string); ok {
+		text += fmt.Sprintf(" `%s`", materialized)
+	}
+	if n.Description != "" {
+		text += "\n\n" + n.Description
+	}
+	return text + fmt.Sprintf("\n\n_%s_", key)
 }
 
 type Macro struct {
-	Name         string `json:"name"`
-	Description  string `json:"description"`
-	OriginalPath string `json:"original_file_path"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	OriginalPath string          `json:"original_file_path"`
+	PackageName  string          `json:"package_name"`
+	Arguments    []MacroArgument `json:"arguments"`
+
+	// Range spans the whole {% macro %} ... {% endmacro %} block
+	Range protocol.Range `json:"-"`
+	// NameRange is where the macro is named in its definition
+	NameRange protocol.Range `json:"-"`
+}
+
+type MacroArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Default is the default value as written in the macro definition, empty when
+	// the argument is required
+	Default string `json:"-"`
+}
+
+func (a MacroArgument) String() string {
+	if a.Default == "" {
+		return a.Name
+	}
+	return a.Name + "=" + a.Default
+}
+
+// Signature returns the macro as it's defined, e.g. cents_to_dollars(column, scale=2)
+func (m Macro) Signature() string {
+	arguments := []string{}
+	for _, argument := range m.Arguments {
+		arguments = append(arguments, argument.String())
+	}
+	return fmt.Sprintf("%s(%s)", m.Name, strings.Join(arguments, ", "))
+}
+
+// Snippet returns a call to the macro with a tab stop for every argument
+func (m Macro) Snippet() string {
+	arguments := []string{}
+	for i, argument := range m.Arguments {
+		arguments = append(arguments, fmt.Sprintf("${%d:%s}", i+1, escapeSnippet(argument.String())))
+	}
+	return fmt.Sprintf("%s(%s)$0", m.Name, strings.Join(arguments, ", "))
+}
+
+func escapeSnippet(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`).Replace(text)
+}
+
+type Source struct {
+	Name              string                `json:"name"`
+	ResourceType      string                `json:"resource_type"`
+	SourceName        string                `json:"source_name"`
+	Description       string                `json:"description"`
+	SourceDescription string                `json:"source_description"`
+	Loader            string                `json:"loader"`
+	OriginalPath      string                `json:"original_file_path"`
+	PackageName       string                `json:"package_name"`
+	Columns           map[string]NodeColumn `json:"columns"`
+	Tags              []string              `json:"tags"`
+	Fqn               []string              `json:"fqn"`
+
+	// Range spans the table's entry in the schema yaml
+	Range protocol.Range `json:"-"`
+	// NameRange is where the table is named in the schema yaml
+	NameRange protocol.Range `json:"-"`
+}
+
+// GetSchemaPath returns the yaml file that documents a model or source
+func (m Manifest) GetSchemaPath(key string) (string, bool) {
+	if source, ok := m.Sources[key]; ok {
+		return source.OriginalPath, true
+	}
+
+	node, ok := m.Nodes[key]
+	return node.SchemaPath, ok && node.SchemaPath != ""
+}
+
+// FindSource looks a source table up by the names used in source('source', 'table')
+func (m Manifest) FindSource(sourceName, tableName string) (string, Source, bool) {
+	for key, source := range m.Sources {
+		if source.SourceName == sourceName && source.Name == tableName {
+			return key, source, true
+		}
+	}
+	return "", Source{}, false
+}
+
+func (m Macro) GetHoverText(key string) string {
+	text := fmt.Sprintf("```jinja\n%s\n```", m.Signature())
+	if m.Description != "" {
+		text += "\n\n" + m.Description
+	}
+	return text + fmt.Sprintf("\n\n_%s_", key)
+}
+
+// ValueString renders the value the way it's written in yaml
+func (v ProjectVar) ValueString() string {
+	if value, ok := v.Value.(string); ok {
+		return value
+	}
+
+	out, err := yaml.Marshal(v.Value)
+	if err != nil {
+		return fmt.Sprintf("%v", v.Value)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (v ProjectVar) GetHoverText(reference VarReference) string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("**var** `%s`\n\n", v.Name))
+	builder.WriteString(fmt.Sprintf("```yaml\n%s\n```\n\n", v.ValueString()))
+	if reference.HasDefault {
+		builder.WriteString(fmt.Sprintf("default: `%s`\n\n", reference.Default))
+	}
+
+	location := fmt.Sprintf("defined in dbt_project.yml line %d", v.NameRange.Start.Line+1)
+	if v.Package != "" {
+		location += fmt.Sprintf(" under `%s`", v.Package)
+	}
+	builder.WriteString(location)
+	return builder.String()
+}
+
+// FindVar looks a var up the way dbt does, vars scoped to the package win over
+// global ones
+func (m Manifest) FindVar(packageName, name string) (ProjectVar, bool) {
+	found, ok := ProjectVar{}, false
+	for _, projectVar := range m.Vars {
+		if projectVar.Name != name {
+			continue
+		}
+
+		if projectVar.Package == packageName {
+			return projectVar, true
+		}
+		if projectVar.Package == "" {
+			found, ok = projectVar, true
+		}
+	}
+	return found, ok
+}
+
+// FindMacro looks a macro up by the name it's called with. Macros in the project
+// win over macros with the same name in other packages, `package.macro` picks one
+// GetRefKey resolves a ref() to the manifest key of its model. Like dbt, a ref
+// without a package prefers the root project and falls back to any installed
+// package that has a model with the name
+func (m Manifest) GetRefKey(ref ModelReference) string {
+	key := ref.Key(m.Metadata.ProjectName)
+	if _, ok := m.Nodes[key]; ok || ref.Package != "" {
+		return key
+	}
+
+	keys := []string{}
+	for nodeKey, node := range m.Nodes {
+		if strings.HasPrefix(nodeKey, "model.") && node.Name == ref.ModelName {
+			keys = append(keys, nodeKey)
+		}
+	}
+	if len(keys) == 0 {
+		return key
+	}
+
+	sort.Strings(keys)
+	return keys[0]
+}
+
+// resolveRefKeys points the dependencies and refs indexed before the package
+// models were known at the package model they resolve to
+func (m *Manifest) resolveRefKeys() {
+	projectPrefix := fmt.Sprintf("model.%s.", m.Metadata.ProjectName)
+	resolve := func(key string) string {
+		name, ok := strings.CutPrefix(key, projectPrefix)
+		if !ok {
+			return key
+		}
+		return m.GetRefKey(ModelReference{ModelName: name})
+	}
+
+	for key, node := range m.Nodes {
+		changed := false
+		dependencies := slices.Clone(node.Depends.Nodes)
+		for i, dependency := range dependencies {
+			if resolved := resolve(dependency); resolved != dependency {
+				dependencies[i] = resolved
+				changed = true
+			}
+		}
+		if changed {
+			node.Depends.Nodes = dependencies
+			m.Nodes[key] = node
+		}
+	}
+
+	for key, references := range m.References {
+		if resolved := resolve(key); resolved != key {
+			m.References[resolved] = append(m.References[resolved], references...)
+			delete(m.References, key)
+		}
+	}
+}
+
+func (m Manifest) FindMacro(name string) (string, Macro, bool) {
+	if strings.Contains(name, ".") {
+		key := "macro." + name
+		macro, ok := m.Macros[key]
+		return key, macro, ok
+	}
+
+	key := fmt.Sprintf("macro.%s.%s", m.Metadata.ProjectName, name)
+	if macro, ok := m.Macros[key]; ok {
+		return key, macro, true
+	}
+
+	keys := []string{}
+	for key, macro := range m.Macros {
+		if macro.Name == name {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return "", Macro{}, false
+	}
+
+	sort.Strings(keys)
+	return keys[0], m.Macros[keys[0]], true
+}
+
+func (s Source) GetHoverText() string {
+	text := fmt.Sprintf("**%s.%s**", s.SourceName, s.Name)
+	if s.Description != "" {
+		text += "\n\n" + s.Description
+	}
+	if s.Loader != "" {
+		text += fmt.Sprintf("\n\nloader: `%s`", s.Loader)
+	}
+	return text
+}
+
+// Checksum is how dbt tells whether a file changed since the manifest was written
+type Checksum struct {
+	Name     string `json:"name"`
+	Checksum string `json:"checksum"`
 }
 
 type NodeColumn struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	DataType    string `json:"data_type"`
+
+	// Range spans the column's entry in the schema yaml, NameRange its name
+	Range     protocol.Range `json:"-"`
+	NameRange protocol.Range `json:"-"`
 }
 
 type Depends struct {
@@ -42,27 +386,19 @@ type Depends struct {
 }
 
 type DefinitionRequest struct {
-	FileUri     string
+	FileUri string
+	// Content is the text of the document, including unsaved edits
+	Content     string
 	ProjectName string
 	Manifest    Manifest
 	Position    protocol.Position
 }
 
-type DefinitionResponse struct {
-	FileName string
-}
-
-func (n Node) GetDefinition(params DefinitionRequest) (DefinitionResponse, error) {
+func (n Node) GetDefinition(params DefinitionRequest) ([]protocol.LocationLink, error) {
 	logger := commonlog.GetLogger("node.GetDefinition")
 	parser := NewJinjaParser()
 
-	fileContent, err := ReadFileUri(params.FileUri)
-	if err != nil {
-		logger.Infof("couldn't read file %v", err)
-		return DefinitionResponse{}, err
-	}
-
-	fileString := string(fileContent)
+	fileString := params.Content
 	if parser.HasJinjaBlocks(fileString) {
 		positions := parser.GetJinjaPositions(fileString)
 		rawPosition := getRawPositionInFile(fileString, params.Position.Line, params.Position.Character)
@@ -78,45 +414,113 @@ func (n Node) GetDefinition(params DefinitionRequest) (DefinitionResponse, error
 		logger.Info("No jinja block found")
 	}
 
-	// handle sql definition
+	return n.getColumnDefinition(params, fileString)
+}
+
+// getColumnDefinition links a column in the sql body to its entry in the schema
+// yaml of the model or source it belongs to
+func (n Node) getColumnDefinition(params DefinitionRequest, content string) ([]protocol.LocationLink, error) {
+	rawPosition := getRawPositionInFile(content, params.Position.Line, params.Position.Character)
+	nodeKey := fmt.Sprintf("model.%s.%s", params.ProjectName, n.Name)
 
-	return DefinitionResponse{}, nil
+	column, ok := getColumnAtPosition(params.Manifest, nodeKey, content, rawPosition)
+	if !ok {
+		return nil, nil
+	}
+
+	schemaPath, ok := params.Manifest.GetSchemaPath(column.OwnerKey)
+	if !ok {
+		return nil, nil
+	}
+
+	origin := getRangeInFile(content, column.Range)
+	return []protocol.LocationLink{{
+		OriginSelectionRange: &origin,
+		TargetURI:            schemaPath,
+		TargetRange:          column.Column.Range,
+		TargetSelectionRange: column.Column.NameRange,
+	}}, nil
 }
 
-func (n Node) getJinjaDefinition(params DefinitionRequest, rawPosition int, content string, parser JinjaParser) (DefinitionResponse, error) {
+// getJinjaDefinition links the ref, source or macro call under the cursor to
+// where it's defined, a model links to its sql file and its schema yaml entry
+func (n Node) getJinjaDefinition(params DefinitionRequest, rawPosition int, content string, parser *JinjaParser) ([]protocol.LocationLink, error) {
 	logger := commonlog.GetLogger("lsp.getJinjaDefinition")
 
 	refTags := parser.GetAllRefTags(content)
 	for _, tag := range refTags {
 		if rawPosition >= tag.Range.Start && rawPosition <= tag.Range.End {
-			model := fmt.Sprintf("model.%s.%s", params.ProjectName, tag.ModelName)
+			model := params.Manifest.GetRefKey(tag)
 			node, ok := params.Manifest.Nodes[model]
 
 			logger.Infof("looking for model %v", model)
 			if !ok {
-				return DefinitionResponse{}, nil
+				return nil, nil
 			}
-			return DefinitionResponse{FileName: node.OriginalPath}, nil
+
+			origin := getRangeInFile(content, tag.NameRange)
+			links := []protocol.LocationLink{{
+				OriginSelectionRange: &origin,
+				TargetURI:            node.OriginalPath,
+				TargetRange:          node.Range,
+				TargetSelectionRange: protocol.Range{Start: node.Range.Start, End: node.Range.Start},
+			}}
+			if node.SchemaPath != "" {
+				links = append(links, protocol.LocationLink{
+					OriginSelectionRange: &origin,
+					TargetURI:            node.SchemaPath,
+					TargetRange:          node.SchemaRange,
+					TargetSelectionRange: node.SchemaNameRange,
+				})
+			}
+			return links, nil
 		}
 	}
 	logger.Info("not within ref tag")
 
+	for _, tag := range parser.GetAllSourceTags(content) {
+		if rawPosition >= tag.Range.Start && rawPosition <= tag.Range.End {
+			key, source, ok := params.Manifest.FindSource(tag.SourceName, tag.TableName)
+
+			logger.Infof("looking for source %v", key)
+			if !ok {
+				return nil, nil
+			}
+
+			origin := getRangeInFile(content, Range{Start: tag.SourceRange.Start, End: tag.TableRange.End})
+			return []protocol.LocationLink{{
+				OriginSelectionRange: &origin,
+				TargetURI:            source.OriginalPath,
+				TargetRange:          source.Range,
+				TargetSelectionRange: source.NameRange,
+			}}, nil
+		}
+	}
+	logger.Info("not within source tag")
+
 	macros := parser.GetMacros(content)
 	logger.Infof("could not find a ref tag trying macro %v", macros)
 	for _, macro := range macros {
 		if rawPosition >= macro.Range.Start && rawPosition <= macro.Range.End {
-			model := fmt.Sprintf("macro.%s.%s", params.ProjectName, macro.ModelName)
-			node, ok := params.Manifest.Macros[model]
+			key, node, ok := params.Manifest.FindMacro(macro.QualifiedName())
 
-			logger.Infof("looking for macro %v", model)
+			logger.Infof("looking for macro %v", macro.QualifiedName())
 			if !ok {
-				return DefinitionResponse{}, nil
+				return nil, nil
 			}
-			return DefinitionResponse{FileName: node.OriginalPath}, nil
+
+			logger.Infof("found macro %v", key)
+			origin := getRangeInFile(content, macro.NameRange)
+			return []protocol.LocationLink{{
+				OriginSelectionRange: &origin,
+				TargetURI:            node.OriginalPath,
+				TargetRange:          node.Range,
+				TargetSelectionRange: node.NameRange,
+			}}, nil
 		}
 	}
 
 	logger.Info("not withing macro")
 
-	return DefinitionResponse{}, nil
+	return nil, nil
 }
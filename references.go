@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func (w *Workspace) referencesHandler(context *glsp.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
+	referencesLog := commonlog.GetLoggerf("%s.references", lsName)
+	manifest := w.Manifest()
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		referencesLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	key := getModelKeyAtPosition(manifest, params.TextDocument.URI, string(fileContent), params.Position)
+	node, ok := manifest.Nodes[key]
+	if !ok {
+		referencesLog.Infof("could not find model %v", key)
+		return nil, nil
+	}
+
+	locations := []protocol.Location{}
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, getDeclarationLocation(node))
+	}
+
+	for _, reference := range getReferences(manifest, w.Documents.Snapshot(), key) {
+		locations = append(locations, protocol.Location{URI: reference.FileUri, Range: reference.Range})
+	}
+
+	referencesLog.Infof("found %v references to %v", len(locations), key)
+	return locations, nil
+}
+
+// getDeclarationLocation spans the model's sql file, or its schema yaml entry
+// when we don't know where the file ends
+func getDeclarationLocation(node Node) protocol.Location {
+	if node.Range == (protocol.Range{}) && node.SchemaPath != "" {
+		return protocol.Location{URI: node.SchemaPath, Range: node.SchemaNameRange}
+	}
+	return protocol.Location{URI: node.OriginalPath, Range: node.Range}
+}
+
+// getModelKeyAtPosition returns the model referenced by the ref() under the cursor,
+// or the model the file itself defines when the cursor is not on a ref
+func getModelKeyAtPosition(manifest Manifest, uri, content string, position protocol.Position) string {
+	parser := NewJinjaParser()
+	rawPosition := getRawPositionInFile(content, position.Line, position.Character)
+
+	for _, ref := range parser.GetAllRefTags(content) {
+		if rawPosition >= ref.Range.Start && rawPosition <= ref.Range.End {
+			return manifest.GetRefKey(ref)
+		}
+	}
+
+	return fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, getModelNameFromFilePath(uri))
+}
+
+// getReferences returns every ref() pointing at the model key. Files that are open
+// in the editor are parsed again so the ranges match the unsaved buffer
+func getReferences(manifest Manifest, openDocuments map[string]string, key string) []ReferenceLocation {
+	parser := NewJinjaParser()
+	references := []ReferenceLocation{}
+
+	for _, reference := range manifest.References[key] {
+		if _, ok := openDocuments[documentKey(reference.FileUri)]; ok {
+			continue
+		}
+		references = append(references, reference)
+	}
+
+	for path, content := range openDocuments {
+		if filepath.Ext(path) != ".sql" {
+			continue
+		}
+
+		uri := fmt.Sprintf("file://%v", path)
+		nodeKey := fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, getModelNameFromFilePath(path))
+		for _, ref := range parser.GetAllRefTags(content) {
+			if manifest.GetRefKey(ref) != key {
+				continue
+			}
+
+			references = append(references, ReferenceLocation{
+				NodeKey:   nodeKey,
+				FileUri:   uri,
+				Range:     getRangeInFile(content, ref.Range),
+				NameRange: getRangeInFile(content, ref.NameRange),
+			})
+		}
+	}
+
+	return references
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestReferenceIndex(t *testing.T) {
+	settings := ProjectSettings{
+		Name:         "test",
+		RootPath:     "./tests",
+		PathSettings: pathSettings{ModelPath: []string{"."}},
+	}
+
+	manifest, err := settings.PredictManifestFile("test", map[string]Node{})
+	if err != nil {
+		t.Fatalf("error %v", err)
+	}
+
+	references := getReferences(manifest, map[string]string{}, "model.test.my_first_dbt_model")
+	if len(references) != 3 {
+		t.Fatalf("expected 3 references but got %v", len(references))
+	}
+
+	for _, reference := range references {
+		if reference.NodeKey == "model.test.second_dbt_model_2" && reference.Range.Start.Line != 4 {
+			t.Errorf("expected reference on line 4 but got %v", reference.Range.Start.Line)
+		}
+	}
+
+	declaration := getDeclarationLocation(manifest.Nodes["model.test.second_dbt_model"])
+	if declaration.URI != manifest.Nodes["model.test.second_dbt_model"].OriginalPath || declaration.Range.End.Line == 0 {
+		t.Errorf("expected the declaration to span the model file but got %+v", declaration)
+	}
+}
+
+func TestReferencesUseOpenDocuments(t *testing.T) {
+	manifest := Manifest{
+		Metadata: Metadata{ProjectName: "test"},
+		References: map[string][]ReferenceLocation{
+			"model.test.customers": {{NodeKey: "model.test.orders", FileUri: "file:///project/models/orders.sql"}},
+		},
+	}
+
+	openDocuments := map[string]string{
+		"/project/models/orders.sql": "select 1\nunion all\nselect * from {{ ref('customers') }}",
+	}
+
+	references := getReferences(manifest, openDocuments, "model.test.customers")
+	if len(references) != 1 {
+		t.Fatalf("expected 1 reference but got %v", len(references))
+	}
+
+	if references[0].Range.Start.Line != 2 || references[0].NodeKey != "model.test.orders" {
+		t.Errorf("expected the reference from the open buffer but got %v", references[0])
+	}
+}
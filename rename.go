@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+var modelNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (w *Workspace) prepareRenameHandler(context *glsp.Context, params *protocol.PrepareRenameParams) (any, error) {
+	renameLog := commonlog.GetLoggerf("%s.prepareRename", lsName)
+	manifest := w.Manifest()
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		renameLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	content := string(fileContent)
+	rawPosition := getRawPositionInFile(content, params.Position.Line, params.Position.Character)
+
+	parser := NewJinjaParser()
+	for _, ref := range parser.GetAllRefTags(content) {
+		if rawPosition < ref.Range.Start || rawPosition > ref.Range.End {
+			continue
+		}
+
+		if _, ok := getRenameableNode(manifest, manifest.GetRefKey(ref)); !ok {
+			return nil, nil
+		}
+		return getRangeInFile(content, ref.NameRange), nil
+	}
+
+	key := fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, getModelNameFromFilePath(params.TextDocument.URI))
+	node, ok := getRenameableNode(manifest, key)
+	if !ok {
+		return nil, nil
+	}
+
+	// the model name isn't written in its own file, the word under the cursor gives
+	// the editor something to highlight while the placeholder holds the name
+	return protocol.RangeWithPlaceholder{
+		Range:       getRangeInFile(content, getWordRange(content, rawPosition)),
+		Placeholder: node.Name,
+	}, nil
+}
+
+// getWordRange returns the identifier at rawPosition, or the first line of the
+// content when the cursor isn't on one
+func getWordRange(content string, rawPosition int) Range {
+	isWordCharacter := func(ch byte) bool {
+		return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+	}
+
+	start, end := rawPosition, rawPosition
+	for start > 0 && isWordCharacter(content[start-1]) {
+		start--
+	}
+	for end < len(content) && isWordCharacter(content[end]) {
+		end++
+	}
+
+	if start == end {
+		lineEnd := strings.IndexByte(content, '\n')
+		if lineEnd == -1 {
+			lineEnd = len(content)
+		}
+		return Range{Start: 0, End: lineEnd}
+	}
+	return Range{Start: start, End: end}
+}
+
+func (w *Workspace) renameHandler(context *glsp.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	renameLog := commonlog.GetLoggerf("%s.rename", lsName)
+	settings, manifest := w.Snapshot()
+
+	if !modelNamePattern.MatchString(params.NewName) {
+		return nil, fmt.Errorf("%v is not a valid model name", params.NewName)
+	}
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		renameLog.Infof("couldn't read file %v", err)
+		return nil, err
+	}
+
+	key := getModelKeyAtPosition(manifest, params.TextDocument.URI, string(fileContent), params.Position)
+	node, ok := getRenameableNode(manifest, key)
+	if !ok {
+		return nil, fmt.Errorf("could not find a model to rename at this position")
+	}
+
+	schemaFiles := map[string][]byte{}
+	paths, err := settings.GetSchemaFilePaths()
+	if err != nil {
+		renameLog.Infof("could not find schema files %v", err)
+	}
+	for _, path := range paths {
+		uri := fmt.Sprintf("file://%v", path)
+		if content, err := w.Documents.ReadFile(uri); err == nil {
+			schemaFiles[uri] = content
+		}
+	}
+
+	renameLog.Infof("renaming %v to %v", key, params.NewName)
+	edit := getRenameEdit(node, getReferences(manifest, w.Documents.Snapshot(), key), schemaFiles, params.NewName, w.DocumentChanges(), w.RenameFiles())
+	return &edit, nil
+}
+
+// getRenameableNode only allows renaming models that live in the root project
+func getRenameableNode(manifest Manifest, key string) (Node, bool) {
+	node, ok := manifest.Nodes[key]
+	if !ok || node.OriginalPath == "" || !strings.HasPrefix(key, fmt.Sprintf("model.%s.", manifest.Metadata.ProjectName)) {
+		return Node{}, false
+	}
+	return node, true
+}
+
+// getRenameEdit renames the refs and schema entries of the model, the model file
+// is only renamed when the client can rename files. Clients without document
+// changes get the plain changes map and keep the old file name
+func getRenameEdit(node Node, references []ReferenceLocation, schemaFiles map[string][]byte, newName string, documentChanges, renameFile bool) protocol.WorkspaceEdit {
+	edits := map[string][]any{}
+	uris := []string{}
+
+	addEdit := func(uri string, r protocol.Range) {
+		if _, ok := edits[uri]; !ok {
+			uris = append(uris, uri)
+		}
+		edits[uri] = append(edits[uri], protocol.TextEdit{Range: r, NewText: newName})
+	}
+
+	for _, reference := range references {
+		addEdit(reference.FileUri, reference.NameRange)
+	}
+
+	schemaUris := []string{}
+	for uri := range schemaFiles {
+		schemaUris = append(schemaUris, uri)
+	}
+	slices.Sort(schemaUris)
+
+	for _, uri := range schemaUris {
+		for _, r := range getSchemaModelNameRanges(schemaFiles[uri], node.Name) {
+			addEdit(uri, r)
+		}
+	}
+
+	if !documentChanges {
+		changes := map[protocol.DocumentUri][]protocol.TextEdit{}
+		for _, uri := range uris {
+			for _, edit := range edits[uri] {
+				changes[uri] = append(changes[uri], edit.(protocol.TextEdit))
+			}
+		}
+		return protocol.WorkspaceEdit{Changes: changes}
+	}
+
+	changes := []any{}
+	for _, uri := range uris {
+		changes = append(changes, protocol.TextDocumentEdit{
+			TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+			},
+			Edits: edits[uri],
+		})
+	}
+
+	if !renameFile {
+		return protocol.WorkspaceEdit{DocumentChanges: changes}
+	}
+
+	oldPath := strings.TrimPrefix(node.OriginalPath, "file://")
+	newPath := filepath.Join(filepath.Dir(oldPath), newName+filepath.Ext(oldPath))
+	changes = append(changes, protocol.RenameFile{
+		Kind:   "rename",
+		OldURI: node.OriginalPath,
+		NewURI: fmt.Sprintf("file://%v", filepath.ToSlash(newPath)),
+	})
+
+	return protocol.WorkspaceEdit{DocumentChanges: changes}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestSchemaModelNameRanges(t *testing.T) {
+	fileContent, _ := os.ReadFile("./tests/schema.yml")
+
+	ranges := getSchemaModelNameRanges(fileContent, "my_second_dbt_model")
+	if len(ranges) != 1 {
+		t.Fatalf("expected 1 range but got %v", len(ranges))
+	}
+
+	if ranges[0].Start.Line != 11 || ranges[0].Start.Character != 10 || ranges[0].End.Character != 29 {
+		t.Errorf("got wrong range %v", ranges[0])
+	}
+}
+
+func TestRenameEdit(t *testing.T) {
+	schemaContent, _ := os.ReadFile("./tests/schema.yml")
+	node := Node{Name: "my_first_dbt_model", OriginalPath: "file:///project/models/my_first_dbt_model.sql"}
+	nameRange := protocol.Range{Start: protocol.Position{Line: 1, Character: 13}, End: protocol.Position{Line: 1, Character: 31}}
+	references := []ReferenceLocation{
+		{FileUri: "file:///project/models/second_dbt_model.sql", NameRange: nameRange},
+	}
+
+	edit := getRenameEdit(node, references, map[string][]byte{"file:///project/models/schema.yml": schemaContent}, "customers", true, true)
+	if len(edit.DocumentChanges) != 3 {
+		t.Fatalf("expected 3 document changes but got %v", len(edit.DocumentChanges))
+	}
+
+	refEdit := edit.DocumentChanges[0].(protocol.TextDocumentEdit)
+	if refEdit.TextDocument.URI != "file:///project/models/second_dbt_model.sql" || refEdit.Edits[0].(protocol.TextEdit).NewText != "customers" {
+		t.Errorf("got wrong ref edit %v", refEdit)
+	}
+
+	schemaEdit := edit.DocumentChanges[1].(protocol.TextDocumentEdit)
+	if schemaEdit.Edits[0].(protocol.TextEdit).Range.Start.Line != 3 {
+		t.Errorf("got wrong schema edit %v", schemaEdit)
+	}
+
+	renameFile := edit.DocumentChanges[2].(protocol.RenameFile)
+	if renameFile.NewURI != "file:///project/models/customers.sql" {
+		t.Errorf("got wrong new uri %v", renameFile.NewURI)
+	}
+
+	// clients that can't rename files only get the text edits
+	edit = getRenameEdit(node, references, map[string][]byte{"file:///project/models/schema.yml": schemaContent}, "customers", true, false)
+	if len(edit.DocumentChanges) != 2 {
+		t.Errorf("expected only the text edits but got %v", edit.DocumentChanges)
+	}
+
+	// clients without document changes get the changes map and no file rename
+	edit = getRenameEdit(node, references, map[string][]byte{"file:///project/models/schema.yml": schemaContent}, "customers", false, true)
+	if edit.DocumentChanges != nil || len(edit.Changes) != 2 || edit.Changes["file:///project/models/second_dbt_model.sql"][0].NewText != "customers" {
+		t.Errorf("expected only the changes map but got %v", edit)
+	}
+}
+
+func TestWordRange(t *testing.T) {
+	content := "select id\nfrom {{ ref('orders') }}"
+
+	if r := getWordRange(content, 8); content[r.Start:r.End] != "id" {
+		t.Errorf("expected the word under the cursor but got %q", content[r.Start:r.End])
+	}
+	if r := getWordRange(content, 6); content[r.Start:r.End] != "select" {
+		t.Errorf("expected the word before the cursor but got %q", content[r.Start:r.End])
+	}
+	if r := getWordRange(content, 15); content[r.Start:r.End] != "select id" {
+		t.Errorf("expected the first line away from a word but got %q", content[r.Start:r.End])
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/joro550/dbt-language-server/jinja"
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// CallContext is the macro call the cursor is inside the brackets of
+type CallContext struct {
+	Function string
+	// ArgumentIndex counts the commas before the cursor
+	ArgumentIndex int
+	// Keyword is set when the argument being typed is passed as name=value
+	Keyword string
+}
+
+func (w *Workspace) signatureHelpHandler(context *glsp.Context, params *protocol.SignatureHelpParams) (*protocol.SignatureHelp, error) {
+	signatureLog := commonlog.GetLoggerf("%s.signature", lsName)
+	manifest := w.Manifest()
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		signatureLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	fileString := string(fileContent)
+	rawPosition := getRawPositionInFile(fileString, params.Position.Line, params.Position.Character)
+
+	callContext, ok := getCallContext(fileString, rawPosition)
+	if !ok {
+		return nil, nil
+	}
+
+	signatureHelp, ok := getSignatureHelp(manifest, callContext)
+	if !ok {
+		signatureLog.Infof("could not find macro %v", callContext.Function)
+		return nil, nil
+	}
+	return signatureHelp, nil
+}
+
+// getCallContext finds the innermost call whose brackets are open at the cursor
+func getCallContext(content string, rawPosition int) (CallContext, bool) {
+	tokens, ok := getJinjaTokensBeforeCursor(content, rawPosition)
+	if !ok {
+		return CallContext{}, false
+	}
+
+	type frame struct {
+		bracket       int
+		commas        int
+		argumentStart int
+	}
+
+	frames := []frame{}
+	for i, tok := range tokens {
+		switch tok.Token {
+		case jinja.LEFT_BRACKET, jinja.START_COLLECTION, jinja.LEFT_BRACE:
+			frames = append(frames, frame{bracket: i, argumentStart: i + 1})
+		case jinja.RIGHT_BRACKET, jinja.END_COLLECTION, jinja.RIGHT_BRACE:
+			if len(frames) > 0 {
+				frames = frames[:len(frames)-1]
+			}
+		case jinja.COMMA:
+			if len(frames) > 0 {
+				frames[len(frames)-1].commas++
+				frames[len(frames)-1].argumentStart = i + 1
+			}
+		}
+	}
+
+	for i := len(frames) - 1; i >= 0; i-- {
+		current := frames[i]
+		if tokens[current.bracket].Token != jinja.LEFT_BRACKET {
+			continue
+		}
+
+		function := getDottedNameBefore(tokens, current.bracket)
+		if function == "" {
+			continue
+		}
+
+		callContext := CallContext{Function: function, ArgumentIndex: current.commas}
+		if start := current.argumentStart; start+1 < len(tokens) && tokens[start].Token == jinja.IDENT && tokens[start+1].Token == jinja.ASSIGN {
+			callContext.Keyword = tokens[start].Value
+		}
+		return callContext, true
+	}
+
+	return CallContext{}, false
+}
+
+// getDottedNameBefore reads a name like adapter.dispatch backwards from the token
+// before index
+func getDottedNameBefore(tokens []jinja.Token, index int) string {
+	parts := []string{}
+	for i := index - 1; i >= 0; i -= 2 {
+		if tokens[i].Token != jinja.IDENT {
+			break
+		}
+		parts = append([]string{tokens[i].Value}, parts...)
+
+		if i == 0 || tokens[i-1].Token != jinja.DOT {
+			break
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func getSignatureHelp(manifest Manifest, callContext CallContext) (*protocol.SignatureHelp, bool) {
+	_, macro, ok := manifest.FindMacro(callContext.Function)
+	if !ok {
+		return nil, false
+	}
+
+	activeParameter := protocol.UInteger(callContext.ArgumentIndex)
+	parameters := []protocol.ParameterInformation{}
+	for i, argument := range macro.Arguments {
+		parameter := protocol.ParameterInformation{Label: argument.String()}
+		if argument.Description != "" {
+			parameter.Documentation = argument.Description
+		}
+		parameters = append(parameters, parameter)
+
+		if callContext.Keyword != "" && argument.Name == callContext.Keyword {
+			activeParameter = protocol.UInteger(i)
+		}
+	}
+
+	signature := protocol.SignatureInformation{
+		Label:      macro.Signature(),
+		Parameters: parameters,
+	}
+	if macro.Description != "" {
+		signature.Documentation = protocol.MarkupContent{Kind: protocol.MarkupKindMarkdown, Value: macro.Description}
+	}
+
+	activeSignature := protocol.UInteger(0)
+	return &protocol.SignatureHelp{
+		Signatures:      []protocol.SignatureInformation{signature},
+		ActiveSignature: &activeSignature,
+		ActiveParameter: &activeParameter,
+	}, true
+}
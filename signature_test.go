@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestCallContext(t *testing.T) {
+	tests := []struct {
+		content  string
+		ok       bool
+		function string
+		index    int
+		keyword  string
+	}{
+		{`{{ cents_to_dollars(`, true, "cents_to_dollars", 0, ""},
+		{`{{ cents_to_dollars('amount', `, true, "cents_to_dollars", 1, ""},
+		{`{{ cents_to_dollars('amount', scale=`, true, "cents_to_dollars", 1, "scale"},
+		{`{% set x = dbt_utils.star(ref('orders'), except=['a', `, true, "dbt_utils.star", 1, "except"},
+		{`{{ cents_to_dollars(ref('orders'`, true, "ref", 0, ""},
+		{`{{ cents_to_dollars('amount') `, false, "", 0, ""},
+		{`{{ cents_to_dollars('amount') }} (`, false, "", 0, ""},
+	}
+
+	for _, tt := range tests {
+		callContext, ok := getCallContext(tt.content, len(tt.content))
+		if ok != tt.ok {
+			t.Errorf("%v: expected ok %v", tt.content, tt.ok)
+			continue
+		}
+
+		if callContext.Function != tt.function || callContext.ArgumentIndex != tt.index || callContext.Keyword != tt.keyword {
+			t.Errorf("%v: got wrong context %+v", tt.content, callContext)
+		}
+	}
+}
+
+func TestSignatureHelp(t *testing.T) {
+	manifest := Manifest{
+		Metadata: Metadata{ProjectName: "project"},
+		Macros: map[string]Macro{
+			"macro.project.cents_to_dollars": {
+				Name:      "cents_to_dollars",
+				Arguments: []MacroArgument{{Name: "column"}, {Name: "scale", Default: "2"}},
+			},
+		},
+	}
+
+	help, ok := getSignatureHelp(manifest, CallContext{Function: "cents_to_dollars", ArgumentIndex: 0, Keyword: "scale"})
+	if !ok {
+		t.Fatalf("expected signature help")
+	}
+
+	signature := help.Signatures[0]
+	if signature.Label != "cents_to_dollars(column, scale=2)" || len(signature.Parameters) != 2 || signature.Parameters[1].Label != "scale=2" {
+		t.Errorf("got wrong signature %+v", signature)
+	}
+
+	if *help.ActiveParameter != 1 {
+		t.Errorf("expected the keyword argument to be active but got %v", *help.ActiveParameter)
+	}
+
+	if _, ok := getSignatureHelp(manifest, CallContext{Function: "ref"}); ok {
+		t.Errorf("expected no signature for unknown macros")
+	}
+}
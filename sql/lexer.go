@@ -0,0 +1,271 @@
+package sql
+
+import "strings"
+
+type TokenType int
+
+type Token struct {
+	Value string
+	Token TokenType
+	// Start and End are byte offsets into the input
+	Start int
+	End   int
+}
+
+var keywords = map[string]bool{
+	"select":    true,
+	"from":      true,
+	"where":     true,
+	"join":      true,
+	"left":      true,
+	"right":     true,
+	"inner":     true,
+	"outer":     true,
+	"full":      true,
+	"cross":     true,
+	"lateral":   true,
+	"on":        true,
+	"using":     true,
+	"as":        true,
+	"and":       true,
+	"or":        true,
+	"not":       true,
+	"in":        true,
+	"is":        true,
+	"null":      true,
+	"like":      true,
+	"between":   true,
+	"exists":    true,
+	"group":     true,
+	"order":     true,
+	"by":        true,
+	"having":    true,
+	"qualify":   true,
+	"limit":     true,
+	"offset":    true,
+	"union":     true,
+	"intersect": true,
+	"except":    true,
+	"all":       true,
+	"distinct":  true,
+	"with":      true,
+	"case":      true,
+	"when":      true,
+	"then":      true,
+	"else":      true,
+	"end":       true,
+	"over":      true,
+	"partition": true,
+	"window":    true,
+	"asc":       true,
+	"desc":      true,
+}
+
+const (
+	IDENT TokenType = iota
+	QUOTED_IDENT
+	KEYWORD
+	STRING
+	NUMBER
+	DOT
+	COMMA
+	SEMI_COLON
+	LEFT_BRACKET
+	RIGHT_BRACKET
+	OPERATOR
+
+	// a whole {{ }} or {% %} block, the jinja lexer deals with what's inside
+	JINJA
+	COMMENT
+
+	ILLEGAL
+	EOF
+)
+
+type Lexer struct {
+	input        string
+	position     int
+	readPosition int
+	ch           byte
+}
+
+func NewSqlLexer(input string) *Lexer {
+	l := &Lexer{input: input}
+	l.readChar()
+	return l
+}
+
+func (l *Lexer) readChar() {
+	if l.readPosition >= len(l.input) {
+		l.ch = 0
+	} else {
+		l.ch = l.input[l.readPosition]
+	}
+
+	l.position = l.readPosition
+	l.readPosition += 1
+}
+
+func (l *Lexer) peekChar() byte {
+	if l.readPosition >= len(l.input) {
+		return 0
+	}
+	return l.input[l.readPosition]
+}
+
+// Tokenize reads every token up to, but not including, EOF
+func (l *Lexer) Tokenize() []Token {
+	tokens := []Token{}
+	for tok := l.NextToken(); tok.Token != EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+func (l *Lexer) NextToken() Token {
+	l.skipWhitespace()
+
+	start := l.position
+	if l.ch == 0 {
+		return Token{Token: EOF, Value: "", Start: start, End: start}
+	}
+
+	var tokenType TokenType
+	switch l.ch {
+	case '{':
+		switch l.peekChar() {
+		case '{':
+			tokenType = JINJA
+			l.readUntil("}}")
+		case '%':
+			tokenType = JINJA
+			l.readUntil("%}")
+		case '#':
+			tokenType = COMMENT
+			l.readUntil("#}")
+		default:
+			tokenType = OPERATOR
+			l.readChar()
+		}
+
+	case '-':
+		if l.peekChar() == '-' {
+			tokenType = COMMENT
+			l.readUntil("\n")
+		} else {
+			tokenType = OPERATOR
+			l.readChar()
+		}
+
+	case '/':
+		if l.peekChar() == '*' {
+			tokenType = COMMENT
+			l.readUntil("*/")
+		} else {
+			tokenType = OPERATOR
+			l.readChar()
+		}
+
+	case '\'':
+		tokenType = STRING
+		l.readQuoted('\'')
+	case '"':
+		tokenType = QUOTED_IDENT
+		l.readQuoted('"')
+	case '`':
+		tokenType = QUOTED_IDENT
+		l.readQuoted('`')
+	case '[':
+		tokenType = QUOTED_IDENT
+		l.readQuoted(']')
+
+	case '.':
+		tokenType = DOT
+		l.readChar()
+	case ',':
+		tokenType = COMMA
+		l.readChar()
+	case ';':
+		tokenType = SEMI_COLON
+		l.readChar()
+	case '(':
+		tokenType = LEFT_BRACKET
+		l.readChar()
+	case ')':
+		tokenType = RIGHT_BRACKET
+		l.readChar()
+
+	default:
+		if isLetter(l.ch) {
+			for isLetter(l.ch) || isDigit(l.ch) || l.ch == '$' {
+				l.readChar()
+			}
+
+			tokenType = IDENT
+			if keywords[strings.ToLower(l.input[start:l.position])] {
+				tokenType = KEYWORD
+			}
+		} else if isDigit(l.ch) {
+			tokenType = NUMBER
+			for isDigit(l.ch) || l.ch == '.' {
+				l.readChar()
+			}
+		} else if isOperator(l.ch) {
+			tokenType = OPERATOR
+			for isOperator(l.ch) {
+				l.readChar()
+			}
+		} else {
+			tokenType = ILLEGAL
+			l.readChar()
+		}
+	}
+
+	return Token{Token: tokenType, Value: l.input[start:l.position], Start: start, End: l.position}
+}
+
+// readUntil skips the two character opener and consumes input up to and including
+// the terminator, or to the end of the input
+func (l *Lexer) readUntil(terminator string) {
+	start := min(l.position+2, len(l.input))
+	index := strings.Index(l.input[start:], terminator)
+	if index == -1 {
+		l.readPosition = len(l.input)
+	} else {
+		l.readPosition = start + index + len(terminator)
+	}
+	l.readChar()
+}
+
+// readQuoted consumes a quoted string, a doubled closing quote is treated as an escape
+func (l *Lexer) readQuoted(closing byte) {
+	l.readChar()
+	for l.ch != 0 {
+		if l.ch == closing {
+			if l.peekChar() != closing {
+				l.readChar()
+				return
+			}
+			l.readChar()
+		}
+		l.readChar()
+	}
+}
+
+func (l *Lexer) skipWhitespace() {
+	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+		l.readChar()
+	}
+}
+
+func isLetter(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isOperator(ch byte) bool {
+	return strings.IndexByte("=<>!+*%|&^~:", ch) != -1
+}
@@ -0,0 +1,70 @@
+package sql
+
+import "testing"
+
+func Test_SelectTokens(t *testing.T) {
+	input := "select o.id, \"Name\" from {{ ref('orders') }} as o -- comment\nwhere o.total >= 10.5"
+	tests := []Token{
+		{Token: KEYWORD, Value: "select"},
+		{Token: IDENT, Value: "o"},
+		{Token: DOT, Value: "."},
+		{Token: IDENT, Value: "id"},
+		{Token: COMMA, Value: ","},
+		{Token: QUOTED_IDENT, Value: "\"Name\""},
+		{Token: KEYWORD, Value: "from"},
+		{Token: JINJA, Value: "{{ ref('orders') }}"},
+		{Token: KEYWORD, Value: "as"},
+		{Token: IDENT, Value: "o"},
+		{Token: COMMENT, Value: "-- comment\n"},
+		{Token: KEYWORD, Value: "where"},
+		{Token: IDENT, Value: "o"},
+		{Token: DOT, Value: "."},
+		{Token: IDENT, Value: "total"},
+		{Token: OPERATOR, Value: ">="},
+		{Token: NUMBER, Value: "10.5"},
+		{Token: EOF, Value: ""},
+	}
+
+	runTests(input, tests, t)
+}
+
+func Test_StringsAndComments(t *testing.T) {
+	input := "'it''s' /* multi\nline */ {% if true %}{# note #}"
+	tests := []Token{
+		{Token: STRING, Value: "'it''s'"},
+		{Token: COMMENT, Value: "/* multi\nline */"},
+		{Token: JINJA, Value: "{% if true %}"},
+		{Token: COMMENT, Value: "{# note #}"},
+		{Token: EOF, Value: ""},
+	}
+
+	runTests(input, tests, t)
+}
+
+func Test_TokenOffsets(t *testing.T) {
+	input := "select id"
+	tokens := NewSqlLexer(input).Tokenize()
+
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 tokens but got %v", len(tokens))
+	}
+
+	if tokens[1].Start != 7 || tokens[1].End != 9 {
+		t.Fatalf("got wrong offsets %v %v", tokens[1].Start, tokens[1].End)
+	}
+}
+
+func runTests(input string, tokens []Token, t *testing.T) {
+	lexer := NewSqlLexer(input)
+	for i, tt := range tokens {
+		tok := lexer.NextToken()
+
+		if tok.Value != tt.Value {
+			t.Fatalf("test[%d] - token value wrong expected %v, got=%v", i, tt.Value, tok.Value)
+		}
+
+		if tok.Token != tt.Token {
+			t.Fatalf("test[%d] - token type wrong expected %v, got=%v", i, tt.Token, tok.Token)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/joro550/dbt-language-server/sql"
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// commonTableExpression is a `name as (...)` entry of a with clause
+type commonTableExpression struct {
+	Name      string
+	Range     Range
+	NameRange Range
+}
+
+func (w *Workspace) documentSymbolHandler(context *glsp.Context, params *protocol.DocumentSymbolParams) (any, error) {
+	symbolLog := commonlog.GetLoggerf("%s.symbols", lsName)
+
+	fileContent, err := w.Documents.ReadFile(params.TextDocument.URI)
+	if err != nil {
+		symbolLog.Infof("couldn't read file %v", err)
+		return nil, nil
+	}
+
+	return getDocumentSymbols(params.TextDocument.URI, string(fileContent)), nil
+}
+
+func getDocumentSymbols(uri, content string) []protocol.DocumentSymbol {
+	switch strings.ToLower(filepath.Ext(uri)) {
+	case ".yml", ".yaml":
+		return getSchemaSymbols([]byte(content))
+	case ".sql":
+		parser := NewJinjaParser()
+		return mergeSqlSymbols(getMacroSymbols(parser, content), getModelSymbols(parser, content))
+	}
+	return []protocol.DocumentSymbol{}
+}
+
+// mergeSqlSymbols nests the model symbols written inside a macro under it, a file
+// can define macros and select from models at the same time
+func mergeSqlSymbols(macros, models []protocol.DocumentSymbol) []protocol.DocumentSymbol {
+	symbols := slices.Clone(macros)
+	for _, model := range models {
+		owner := slices.IndexFunc(symbols[:len(macros)], func(macro protocol.DocumentSymbol) bool {
+			return !positionBefore(model.Range.Start, macro.Range.Start) && !positionBefore(macro.Range.End, model.Range.End)
+		})
+		if owner == -1 {
+			symbols = append(symbols, model)
+			continue
+		}
+		symbols[owner].Children = append(symbols[owner].Children, model)
+	}
+
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return positionBefore(symbols[i].Range.Start, symbols[j].Range.Start)
+	})
+	return symbols
+}
+
+func positionBefore(a, b protocol.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
+
+func getMacroSymbols(parser *JinjaParser, content string) []protocol.DocumentSymbol {
+	symbols := []protocol.DocumentSymbol{}
+	for _, macro := range parser.GetMacroDefinitions(content) {
+		symbols = append(symbols, protocol.DocumentSymbol{
+			Name:           macro.ModelName,
+			Kind:           protocol.SymbolKindFunction,
+			Range:          getRangeInFile(content, macro.Range),
+			SelectionRange: getRangeInFile(content, macro.NameRange),
+		})
+	}
+	return symbols
+}
+
+// getModelSymbols lists the CTEs of a model with the refs and sources they select
+// from nested under them, dependencies outside of any CTE sit at the top level
+func getModelSymbols(parser *JinjaParser, content string) []protocol.DocumentSymbol {
+	type symbol struct {
+		start  int
+		symbol protocol.DocumentSymbol
+	}
+
+	dependencies := []symbol{}
+	for _, ref := range parser.GetAllRefTags(content) {
+		name := ref.ModelName
+		if ref.Package != "" {
+			name = ref.Package + "." + name
+		}
+
+		dependencies = append(dependencies, symbol{start: ref.Range.Start, symbol: protocol.DocumentSymbol{
+			Name:           name,
+			Detail:         stringPointer("ref"),
+			Kind:           protocol.SymbolKindFile,
+			Range:          getRangeInFile(content, ref.Range),
+			SelectionRange: getRangeInFile(content, ref.NameRange),
+		}})
+	}
+
+	for _, source := range parser.GetAllSourceTags(content) {
+		dependencies = append(dependencies, symbol{start: source.Range.Start, symbol: protocol.DocumentSymbol{
+			Name:           fmt.Sprintf("%s.%s", source.SourceName, source.TableName),
+			Detail:         stringPointer("source"),
+			Kind:           protocol.SymbolKindStruct,
+			Range:          getRangeInFile(content, source.Range),
+			SelectionRange: getRangeInFile(content, Range{Start: source.SourceRange.Start, End: source.TableRange.End}),
+		}})
+	}
+
+	sort.SliceStable(dependencies, func(i, j int) bool {
+		return dependencies[i].start < dependencies[j].start
+	})
+
+	ctes := getCommonTableExpressions(sql.NewSqlLexer(content).Tokenize())
+	children := make([][]protocol.DocumentSymbol, len(ctes))
+	symbols := []symbol{}
+
+	// dependencies belong to the innermost CTE they are written in
+	for _, dependency := range dependencies {
+		owner := -1
+		for i, cte := range ctes {
+			if dependency.start < cte.Range.Start || dependency.start >= cte.Range.End {
+				continue
+			}
+			if owner == -1 || cte.Range.End-cte.Range.Start < ctes[owner].Range.End-ctes[owner].Range.Start {
+				owner = i
+			}
+		}
+
+		if owner == -1 {
+			symbols = append(symbols, dependency)
+			continue
+		}
+		children[owner] = append(children[owner], dependency.symbol)
+	}
+
+	for i, cte := range ctes {
+		symbols = append(symbols, symbol{start: cte.Range.Start, symbol: protocol.DocumentSymbol{
+			Name:           cte.Name,
+			Detail:         stringPointer("cte"),
+			Kind:           protocol.SymbolKindNamespace,
+			Range:          getRangeInFile(content, cte.Range),
+			SelectionRange: getRangeInFile(content, cte.NameRange),
+			Children:       children[i],
+		}})
+	}
+
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].start < symbols[j].start
+	})
+
+	result := []protocol.DocumentSymbol{}
+	for _, s := range symbols {
+		result = append(result, s.symbol)
+	}
+	return result
+}
+
+// getCommonTableExpressions finds every `name as (...)` in the with clauses of the
+// query, including with clauses nested in subqueries
+func getCommonTableExpressions(tokens []sql.Token) []commonTableExpression {
+	ctes := []commonTableExpression{}
+
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].Token != sql.KEYWORD || !strings.EqualFold(tokens[i].Value, "with") {
+			continue
+		}
+
+		next := i + 1
+		if next < len(tokens) && tokens[next].Token == sql.IDENT && strings.EqualFold(tokens[next].Value, "recursive") {
+			next++
+		}
+
+		for {
+			cte, end, ok := readCommonTableExpression(tokens, next)
+			if !ok {
+				break
+			}
+			ctes = append(ctes, cte)
+
+			if end+1 >= len(tokens) || tokens[end+1].Token != sql.COMMA {
+				break
+			}
+			next = end + 2
+		}
+	}
+
+	return ctes
+}
+
+// readCommonTableExpression reads `name [(columns)] as [not] [materialized] (...)`
+// starting at index and returns the index of the closing bracket
+func readCommonTableExpression(tokens []sql.Token, index int) (commonTableExpression, int, bool) {
+	if index >= len(tokens) || (tokens[index].Token != sql.IDENT && tokens[index].Token != sql.QUOTED_IDENT) {
+		return commonTableExpression{}, 0, false
+	}
+	name := tokens[index]
+
+	i := index + 1
+	if i < len(tokens) && tokens[i].Token == sql.LEFT_BRACKET {
+		i = matchingBracket(tokens, i) + 1
+	}
+
+	if i >= len(tokens) || tokens[i].Token != sql.KEYWORD || !strings.EqualFold(tokens[i].Value, "as") {
+		return commonTableExpression{}, 0, false
+	}
+	i++
+
+	for i < len(tokens) && (strings.EqualFold(tokens[i].Value, "not") || strings.EqualFold(tokens[i].Value, "materialized")) {
+		i++
+	}
+
+	if i >= len(tokens) || tokens[i].Token != sql.LEFT_BRACKET {
+		return commonTableExpression{}, 0, false
+	}
+
+	end := matchingBracket(tokens, i)
+	if end >= len(tokens) {
+		end = len(tokens) - 1
+	}
+
+	return commonTableExpression{
+		Name:      unquoteIdentifier(name.Value),
+		Range:     Range{Start: name.Start, End: tokens[end].End},
+		NameRange: Range{Start: name.Start, End: name.End},
+	}, end, true
+}
+
+// matchingBracket returns the index of the bracket closing the one at index, or
+// len(tokens) when it's never closed
+func matchingBracket(tokens []sql.Token, index int) int {
+	depth := 0
+	for i := index; i < len(tokens); i++ {
+		switch tokens[i].Token {
+		case sql.LEFT_BRACKET:
+			depth++
+		case sql.RIGHT_BRACKET:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// getSchemaSymbols lists the models and sources of a schema file with their
+// columns and tables as children
+func getSchemaSymbols(content []byte) []protocol.DocumentSymbol {
+	symbols := []protocol.DocumentSymbol{}
+
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(content, &document); err != nil || len(document.Content) == 0 {
+		return symbols
+	}
+
+	root := document.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		switch root.Content[i].Value {
+		case "models":
+			for _, model := range root.Content[i+1].Content {
+				if symbol, ok := getYamlEntrySymbol(model, protocol.SymbolKindFile, "columns", protocol.SymbolKindField); ok {
+					symbols = append(symbols, symbol)
+				}
+			}
+		case "sources":
+			for _, source := range root.Content[i+1].Content {
+				symbol, ok := getYamlEntrySymbol(source, protocol.SymbolKindModule, "tables", protocol.SymbolKindStruct)
+				if !ok {
+					continue
+				}
+
+				// tables have columns of their own
+				if tables := getYamlMappingValue(source, "tables"); tables != nil {
+					j := 0
+					for _, table := range tables.Content {
+						columns, ok := getYamlEntrySymbol(table, protocol.SymbolKindStruct, "columns", protocol.SymbolKindField)
+						if !ok {
+							continue
+						}
+
+						symbol.Children[j].Children = columns.Children
+						j++
+					}
+				}
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+
+	return symbols
+}
+
+// getYamlEntrySymbol turns a `- name: x` mapping into a symbol, with a child for
+// every named entry in the list under childKey
+func getYamlEntrySymbol(entry *yaml.Node, kind protocol.SymbolKind, childKey string, childKind protocol.SymbolKind) (protocol.DocumentSymbol, bool) {
+	name := getYamlMappingValue(entry, "name")
+	if entry.Kind != yaml.MappingNode || name == nil {
+		return protocol.DocumentSymbol{}, false
+	}
+
+	symbol := protocol.DocumentSymbol{
+		Name:           name.Value,
+		Kind:           kind,
+		Range:          getYamlNodeRange(entry),
+		SelectionRange: getYamlValueRange(name),
+		Children:       []protocol.DocumentSymbol{},
+	}
+
+	if description := getYamlMappingValue(entry, "description"); description != nil && description.Value != "" {
+		symbol.Detail = stringPointer(strings.SplitN(description.Value, "\n", 2)[0])
+	}
+
+	if children := getYamlMappingValue(entry, childKey); children != nil {
+		for _, child := range children.Content {
+			childName := getYamlMappingValue(child, "name")
+			if childName == nil {
+				continue
+			}
+
+			symbol.Children = append(symbol.Children, protocol.DocumentSymbol{
+				Name:           childName.Value,
+				Kind:           childKind,
+				Range:          getYamlNodeRange(child),
+				SelectionRange: getYamlValueRange(childName),
+			})
+		}
+	}
+
+	return symbol, true
+}
+
+func getYamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// getYamlNodeRange spans from the start of the node to the end of the last scalar
+// inside it, yaml.Node doesn't track where a node ends
+func getYamlNodeRange(node *yaml.Node) protocol.Range {
+	last := node
+	for len(last.Content) > 0 {
+		last = last.Content[len(last.Content)-1]
+	}
+	end := getYamlValueRange(last).End
+	if last.Style == yaml.SingleQuotedStyle || last.Style == yaml.DoubleQuotedStyle {
+		end.Character += 1
+	}
+
+	return protocol.Range{
+		Start: protocol.Position{Line: uint32(node.Line - 1), Character: uint32(node.Column - 1)},
+		End:   end,
+	}
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
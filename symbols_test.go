@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMacroSymbols(t *testing.T) {
+	content := "{% macro cents_to_dollars(column) %}\n({{ column }} / 100){% endmacro %}\n\n{% macro hello() %}hi{% endmacro %}"
+
+	symbols := getDocumentSymbols("file:///project/macros/utils.sql", content)
+	if len(symbols) != 2 {
+		t.Fatalf("expected 2 symbols but got %v", len(symbols))
+	}
+
+	if symbols[0].Name != "cents_to_dollars" || symbols[0].SelectionRange.Start.Character != 9 || symbols[0].Range.End.Line != 1 {
+		t.Errorf("got wrong macro symbol %+v", symbols[0])
+	}
+
+	if symbols[1].Name != "hello" || symbols[1].Range.Start.Line != 3 {
+		t.Errorf("got wrong macro symbol %+v", symbols[1])
+	}
+}
+
+func TestMacroAndModelSymbols(t *testing.T) {
+	content := "{% macro limit_rows(n) %}\nselect * from {{ ref('stg_orders') }} limit {{ n }}\n{% endmacro %}\n\nselect * from {{ ref('orders') }}"
+
+	symbols := getDocumentSymbols("file:///project/models/orders.sql", content)
+	if len(symbols) != 2 || symbols[0].Name != "limit_rows" || symbols[1].Name != "orders" {
+		t.Fatalf("expected the macro and the model ref but got %+v", symbols)
+	}
+
+	if len(symbols[0].Children) != 1 || symbols[0].Children[0].Name != "stg_orders" {
+		t.Errorf("expected the ref in the macro to be nested under it but got %+v", symbols[0].Children)
+	}
+}
+
+func TestModelSymbols(t *testing.T) {
+	content := `with orders as (
+    select * from {{ ref('stg_orders') }}
+),
+
+"payments" as (
+    select * from {{ source('raw', 'payments') }}
+)
+
+select * from orders
+join {{ ref('customers') }} using (id)`
+
+	symbols := getDocumentSymbols("file:///project/models/orders.sql", content)
+	if len(symbols) != 3 {
+		t.Fatalf("expected 3 symbols but got %+v", symbols)
+	}
+
+	orders := symbols[0]
+	if orders.Name != "orders" || orders.Range.Start.Line != 0 || orders.Range.End.Line != 2 {
+		t.Errorf("got wrong cte %+v", orders)
+	}
+	if len(orders.Children) != 1 || orders.Children[0].Name != "stg_orders" {
+		t.Errorf("expected the ref under orders but got %+v", orders.Children)
+	}
+
+	payments := symbols[1]
+	if payments.Name != "payments" || len(payments.Children) != 1 || payments.Children[0].Name != "raw.payments" {
+		t.Errorf("got wrong cte %+v", payments)
+	}
+
+	if symbols[2].Name != "customers" || symbols[2].Range.Start.Line != 9 {
+		t.Errorf("got wrong top level ref %+v", symbols[2])
+	}
+}
+
+func TestSchemaSymbols(t *testing.T) {
+	content, _ := os.ReadFile("./tests/schema.yml")
+
+	symbols := getDocumentSymbols("file:///project/models/schema.yml", string(content))
+	if len(symbols) != 2 {
+		t.Fatalf("expected 2 symbols but got %v", len(symbols))
+	}
+
+	model := symbols[1]
+	if model.Name != "my_second_dbt_model" || model.SelectionRange.Start.Line != 11 || model.Range.End.Line != 17 {
+		t.Errorf("got wrong model symbol %+v", model)
+	}
+
+	if len(model.Children) != 1 || model.Children[0].Name != "id" {
+		t.Errorf("got wrong columns %+v", model.Children)
+	}
+
+	sourceContent, _ := os.ReadFile("./tests/sources.yml")
+	sources := getDocumentSymbols("file:///project/models/sources.yml", string(sourceContent))
+	if len(sources) != 1 || len(sources[0].Children) != 2 || len(sources[0].Children[0].Children) != 1 {
+		t.Errorf("got wrong source symbols %+v", sources)
+	}
+}
+
+func TestSchemaSymbolsBlockScalar(t *testing.T) {
+	content := "version: 2\nmodels:\n  - name: orders\n    description: |\n      One row per order.\n      Refunds are included.\n  - name: customers\n"
+
+	symbols := getDocumentSymbols("file:///project/models/schema.yml", content)
+	if len(symbols) != 2 {
+		t.Fatalf("expected 2 symbols but got %v", len(symbols))
+	}
+
+	if r := symbols[0].Range; r.Start.Line != 2 || r.End.Line != 6 || r.End.Character != 0 {
+		t.Errorf("expected the model to end after its description but got %v", r)
+	}
+}
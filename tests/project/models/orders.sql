@@ -0,0 +1,4 @@
+select
+    {{ dbt_utils.star(ref('stg_orders')) }},
+    {{ cents_to_dollars('amount') }} as amount
+from {{ ref('stg_orders') }}
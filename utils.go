@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/tliron/commonlog"
+	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 func getModelNameFromFilePath(filePath string) string {
@@ -16,15 +17,59 @@ func getModelNameFromFilePath(filePath string) string {
 	return file
 }
 
+// getRawPositionInFile turns an lsp line/character position into a byte offset.
+// lsp counts characters in utf-16 code units so we walk the line to convert them
 func getRawPositionInFile(content string, line, character uint32) int {
 	// where are we in the file
 	position := 0
 	fileLines := strings.Split(content, "\n")
-	for i := uint32(0); i < line; i++ {
+	for i := uint32(0); i < line && int(i) < len(fileLines); i++ {
 		position += len(fileLines[i]) + 1
 	}
-	position += int(character)
-	return position
+
+	if int(line) >= len(fileLines) {
+		return len(content)
+	}
+
+	units := uint32(0)
+	for offset, r := range fileLines[line] {
+		if units >= character {
+			return position + offset
+		}
+		units += uint32(utf16Length(r))
+	}
+	return position + len(fileLines[line])
+}
+
+// getPositionInFile is the reverse of getRawPositionInFile
+func getPositionInFile(content string, rawPosition int) protocol.Position {
+	if rawPosition > len(content) {
+		rawPosition = len(content)
+	}
+
+	before := content[:rawPosition]
+	lineStart := strings.LastIndex(before, "\n") + 1
+	line := strings.Count(before, "\n")
+
+	character := 0
+	for _, r := range before[lineStart:] {
+		character += utf16Length(r)
+	}
+	return protocol.Position{Line: uint32(line), Character: uint32(character)}
+}
+
+func utf16Length(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func getRangeInFile(content string, r Range) protocol.Range {
+	return protocol.Range{
+		Start: getPositionInFile(content, r.Start),
+		End:   getPositionInFile(content, r.End),
+	}
 }
 
 func positionWithinRange(rawPosition int, ranges []Range) bool {
@@ -56,7 +101,7 @@ func ReadFileUri2(path, file string) ([]byte, error) {
 }
 
 func CleanUri(fileUri string) (string, error) {
-	driveRegex := regexp.MustCompile(`[a-zA-Z]:\/\/`)
+	driveRegex := regexp.MustCompile(`^[\/\\][a-zA-Z]:`)
 	cleanedUri, err := url.ParseRequestURI(fileUri)
 
 	var cleanedPath string
@@ -68,10 +113,8 @@ func CleanUri(fileUri string) (string, error) {
 	}
 
 	// this is basically a "are we in windows" check
-	if driveRegex.MatchString(fileUri) {
-		if cleanedPath[0] == '/' || cleanedPath[0] == '\\' {
-			cleanedPath = cleanedPath[1:]
-		}
+	if driveRegex.MatchString(cleanedPath) {
+		cleanedPath = cleanedPath[1:]
 	}
 	return cleanedPath, nil
 }
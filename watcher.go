@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+const watchedFilesRegistration = "dbt-watched-files"
+
+// registerFileWatchers asks the client to tell us about the files the manifest is
+// built from, it can't be done statically
+func (w *Workspace) registerFileWatchers(context *glsp.Context) {
+	watcherLog := commonlog.GetLoggerf("%s.watcher", lsName)
+	settings := w.Settings()
+
+	targetPath := filepath.ToSlash(settings.PathSettings.TargetPath)
+	params := protocol.RegistrationParams{Registrations: []protocol.Registration{{
+		ID:     watchedFilesRegistration,
+		Method: string(protocol.MethodWorkspaceDidChangeWatchedFiles),
+		RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{Watchers: []protocol.FileSystemWatcher{
+			{GlobPattern: "**/*.sql"},
+			{GlobPattern: "**/*.yml"},
+			{GlobPattern: "**/*.yaml"},
+			{GlobPattern: fmt.Sprintf("**/%s/manifest.json", targetPath)},
+		}},
+	}}}
+
+	// handlers run on the connection's read loop so we can't wait for the reply here
+	go func() {
+		var result any
+		context.Call(protocol.ServerClientRegisterCapability, params, &result)
+		watcherLog.Info("registered file watchers")
+	}()
+}
+
+func (w *Workspace) fileChanged(context *glsp.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	watcherLog := commonlog.GetLoggerf("%s.watcher", lsName)
+
+	affected := []string{}
+	rebuild := false
+	manifest := w.Update(func(manifest *Manifest, settings ProjectSettings) {
+		for _, change := range params.Changes {
+			keys, ok := reindexFile(manifest, settings, change.URI, change.Type)
+			if !ok {
+				rebuild = true
+				continue
+			}
+			affected = append(affected, keys...)
+		}
+	})
+
+	if rebuild {
+		watcherLog.Info("project files changed, rebuilding the manifest")
+		if err := w.Reload(); err != nil {
+			watcherLog.Errorf("could not rebuild the manifest %v", err)
+			return nil
+		}
+		manifest = w.Manifest()
+	}
+
+	watcherLog.Infof("reindexed %v", affected)
+	for path, content := range w.Documents.Snapshot() {
+		key := fmt.Sprintf("model.%s.%s", manifest.Metadata.ProjectName, getModelNameFromFilePath(path))
+		if rebuild || isAffected(manifest, key, affected) {
+			w.publishDiagnostics(context, manifest, w.Documents.URI(path), content)
+		}
+	}
+	return nil
+}
+
+// reindexFile updates the manifest entries built from the file at uri and returns
+// their keys. It returns false when the change needs the whole manifest rebuilt,
+// like edits to dbt_project.yml or a new compiled manifest
+func reindexFile(manifest *Manifest, settings ProjectSettings, uri string, changeType protocol.UInteger) ([]string, bool) {
+	path, err := CleanUri(uri)
+	if err != nil {
+		return nil, true
+	}
+
+	name := filepath.Base(path)
+	if name == "profiles.yml" || name == "selectors.yml" {
+		// connection details and selectors don't change what the manifest holds
+		return nil, true
+	}
+	if name == "manifest.json" || slices.Contains(projectFiles, name) {
+		return nil, false
+	}
+
+	deleted := changeType == protocol.FileChangeTypeDeleted
+	fileString := ""
+	if !deleted {
+		fileContent, err := os.ReadFile(path)
+		if err != nil {
+			return nil, true
+		}
+		fileString = string(fileContent)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		if settings.inPaths(path, settings.PathSettings.ModelPath, settings.PathSettings.SeedPath, settings.PathSettings.SnapshotPath, settings.PathSettings.AnalysisPath, settings.PathSettings.MacroPath) {
+			return reindexSchemaFile(manifest, settings, path, fileString), true
+		}
+	case ".sql":
+		fileUri := fmt.Sprintf("file://%v", path)
+		projectName := manifest.Metadata.ProjectName
+
+		switch {
+		case settings.inPaths(path, settings.PathSettings.ModelPath):
+			key := fmt.Sprintf("model.%v.%v", projectName, strings.TrimSuffix(name, ".sql"))
+			removeReferences(manifest, key)
+
+			existing, ok := manifest.Nodes[key]
+			delete(manifest.Nodes, key)
+			if !deleted {
+				schemas := map[string]Node{}
+				if ok && existing.SchemaPath != "" {
+					schemas[existing.Name] = existing
+				} else if !ok || changeType == protocol.FileChangeTypeCreated {
+					// a new model may already be documented in a schema file
+					if schemas, err = settings.GetSchemaFiles(); err != nil {
+						return nil, false
+					}
+				}
+				settings.indexModel(manifest, projectName, path, fileString, schemas)
+			}
+			return []string{key}, true
+		case settings.inPaths(path, settings.PathSettings.MacroPath):
+			return reindexMacros(manifest, projectName, path, fileString, deleted), true
+		case settings.inPaths(path, settings.PathSettings.SnapshotPath):
+			keys := []string{}
+			for key, node := range manifest.Nodes {
+				if node.ResourceType == "snapshot" && node.OriginalPath == fileUri {
+					keys = append(keys, key)
+					delete(manifest.Nodes, key)
+				}
+			}
+			if !deleted {
+				indexSnapshots(manifest, projectName, path, fileString)
+				for key, node := range manifest.Nodes {
+					if node.ResourceType == "snapshot" && node.OriginalPath == fileUri && !slices.Contains(keys, key) {
+						keys = append(keys, key)
+					}
+				}
+			}
+			return keys, true
+		}
+
+		for _, dependency := range settings.GetPackages() {
+			if dependency.inPaths(path, dependency.PathSettings.MacroPath) {
+				return reindexMacros(manifest, dependency.Name, path, fileString, deleted), true
+			}
+		}
+	}
+
+	return nil, true
+}
+
+// reindexMacros replaces the macros defined in the file at path
+func reindexMacros(manifest *Manifest, projectName, path, fileString string, deleted bool) []string {
+	fileUri := fmt.Sprintf("file://%v", path)
+	keys := []string{}
+
+	for key, macro := range manifest.Macros {
+		if macro.OriginalPath == fileUri {
+			keys = append(keys, key)
+			delete(manifest.Macros, key)
+		}
+	}
+
+	if deleted {
+		return keys
+	}
+
+	for key, macro := range getFileMacros(projectName, path, fileString) {
+		manifest.Macros[key] = macro
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// reindexSchemaFile replaces what the schema file at path documents, the model
+// descriptions and columns and the sources it defines
+func reindexSchemaFile(manifest *Manifest, settings ProjectSettings, path, fileString string) []string {
+	logger := commonlog.GetLoggerf("%s.watcher", lsName)
+	fileUri := fmt.Sprintf("file://%v", path)
+	keys := []string{}
+
+	for key, node := range manifest.Nodes {
+		if node.SchemaPath != fileUri {
+			continue
+		}
+
+		node.Description = ""
+		node.Columns = map[string]NodeColumn{}
+		node.SchemaPath = ""
+		node.SchemaRange = protocol.Range{}
+		node.SchemaNameRange = protocol.Range{}
+		manifest.Nodes[key] = node
+		keys = append(keys, key)
+	}
+
+	if manifest.Sources == nil {
+		manifest.Sources = map[string]Source{}
+	}
+	for key, source := range manifest.Sources {
+		if source.OriginalPath == fileUri {
+			keys = append(keys, key)
+			delete(manifest.Sources, key)
+		}
+	}
+
+	if fileString == "" {
+		return keys
+	}
+
+	model := schemaModel{}
+	if err := yaml.Unmarshal([]byte(fileString), &model); err != nil {
+		logger.Infof("Could not parse yaml file %v , file : %v", err, path)
+		return keys
+	}
+
+	for _, schema := range model.ToNode(path) {
+		for _, resourceType := range []string{"model", "seed"} {
+			key := fmt.Sprintf("%s.%s.%s", resourceType, manifest.Metadata.ProjectName, schema.Name)
+			node, ok := manifest.Nodes[key]
+			if !ok {
+				continue
+			}
+
+			node.Description = schema.Description
+			node.Columns = schema.Columns
+			node.SchemaPath = schema.SchemaPath
+			node.SchemaRange = schema.SchemaRange
+			node.SchemaNameRange = schema.SchemaNameRange
+			manifest.Nodes[key] = node
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	for key, source := range model.ToSources(settings.Name, path) {
+		manifest.Sources[key] = source
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// removeReferences drops the refs made from the model with the key
+func removeReferences(manifest *Manifest, key string) {
+	for refKey, locations := range manifest.References {
+		manifest.References[refKey] = slices.DeleteFunc(locations, func(location ReferenceLocation) bool {
+			return location.NodeKey == key
+		})
+	}
+}
+
+// isAffected is true when the node is one of the changed keys or depends on one,
+// directly or through other nodes
+func isAffected(manifest Manifest, key string, changed []string) bool {
+	seen := map[string]bool{}
+	pending := []string{key}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+
+		if slices.Contains(changed, current) {
+			return true
+		}
+		pending = append(pending, manifest.Nodes[current].Depends.Nodes...)
+	}
+	return false
+}
+
+// inPaths is true when path sits in one of the project directories
+func (settings ProjectSettings) inPaths(path string, directories ...[]string) bool {
+	for _, paths := range directories {
+		for _, directory := range paths {
+			relativePath, err := filepath.Rel(filepath.Join(settings.GetRootDirectory(), directory), path)
+			if err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, "../") {
+				return true
+			}
+		}
+	}
+	return false
+}
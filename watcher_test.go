@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestReindexFile(t *testing.T) {
+	root := copyProject(t, "./tests/project")
+	settings, err := LoadSettings(root)
+	if err != nil {
+		t.Fatalf("could not load settings %v", err)
+	}
+
+	manifest, err := settings.BuildManifest()
+	if err != nil {
+		t.Fatalf("could not build manifest %v", err)
+	}
+
+	// a new model
+	customers := filepath.Join(root, "models", "customers.sql")
+	os.WriteFile(customers, []byte("select * from {{ ref('stg_orders') }}"), 0644)
+	keys, ok := reindexFile(&manifest, settings, "file://"+customers, protocol.FileChangeTypeCreated)
+	if !ok || len(keys) != 1 || keys[0] != "model.jaffle_shop.customers" {
+		t.Fatalf("expected the new model but got %v %v", keys, ok)
+	}
+
+	if references := manifest.References["model.jaffle_shop.stg_orders"]; len(references) != 3 {
+		t.Errorf("expected the new ref to stg_orders but got %v", references)
+	}
+
+	if !isAffected(manifest, "model.jaffle_shop.orders", []string{"model.jaffle_shop.stg_orders"}) || isAffected(manifest, "model.jaffle_shop.orders", []string{"model.jaffle_shop.customers"}) {
+		t.Errorf("only dependents of a changed model are affected")
+	}
+
+	// documenting it
+	schema := filepath.Join(root, "models", "staging", "schema.yml")
+	os.WriteFile(schema, []byte("models:\n  - name: customers\n    description: One row per customer\n"), 0644)
+	keys, _ = reindexFile(&manifest, settings, "file://"+schema, protocol.FileChangeTypeChanged)
+	if len(keys) != 2 || manifest.Nodes["model.jaffle_shop.customers"].Description != "One row per customer" || manifest.Nodes["model.jaffle_shop.stg_orders"].SchemaPath != "" {
+		t.Errorf("expected the schema to move from stg_orders to customers but got %v", keys)
+	}
+
+	// editing the model keeps its schema entry
+	os.WriteFile(customers, []byte("select 1 as id"), 0644)
+	reindexFile(&manifest, settings, "file://"+customers, protocol.FileChangeTypeChanged)
+	if node := manifest.Nodes["model.jaffle_shop.customers"]; node.Description != "One row per customer" || len(node.Depends.Nodes) != 0 {
+		t.Errorf("got wrong model after the edit %+v", node)
+	}
+	if references := manifest.References["model.jaffle_shop.stg_orders"]; len(references) != 2 {
+		t.Errorf("the old ref should be gone but got %v", references)
+	}
+
+	// deleting it
+	os.Remove(customers)
+	reindexFile(&manifest, settings, "file://"+customers, protocol.FileChangeTypeDeleted)
+	if _, ok := manifest.Nodes["model.jaffle_shop.customers"]; ok {
+		t.Errorf("expected the model to be removed")
+	}
+
+	// creating it again picks up the schema entry that is still there
+	os.WriteFile(customers, []byte("select 1 as id"), 0644)
+	reindexFile(&manifest, settings, "file://"+customers, protocol.FileChangeTypeCreated)
+	if node := manifest.Nodes["model.jaffle_shop.customers"]; node.Description != "One row per customer" || node.SchemaPath != "file://"+schema {
+		t.Errorf("expected the new model to be documented but got %+v", node)
+	}
+	os.Remove(customers)
+	reindexFile(&manifest, settings, "file://"+customers, protocol.FileChangeTypeDeleted)
+
+	// a package macro
+	star := filepath.Join(root, "dbt_packages", "dbt_utils", "macros", "sql", "star.sql")
+	os.WriteFile(star, []byte("{% macro star_v2(from) %}{% endmacro %}"), 0644)
+	keys, _ = reindexFile(&manifest, settings, "file://"+star, protocol.FileChangeTypeChanged)
+	if _, ok := manifest.Macros["macro.dbt_utils.star_v2"]; !ok || len(keys) != 2 {
+		t.Errorf("expected star to be replaced by star_v2 but got %v", keys)
+	}
+
+	if _, ok := reindexFile(&manifest, settings, "file://"+filepath.Join(root, "dbt_project.yml"), protocol.FileChangeTypeChanged); ok {
+		t.Errorf("dbt_project.yml changes need a rebuild")
+	}
+}
+
+func copyProject(t *testing.T, source string) string {
+	root := t.TempDir()
+	err := filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, _ := filepath.Rel(source, path)
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(root, relativePath), 0755)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(root, relativePath), content, 0644)
+	})
+	if err != nil {
+		t.Fatalf("could not copy %v: %v", source, err)
+	}
+	return root
+}
@@ -0,0 +1,133 @@
+package main
+
+import "sync"
+
+// Workspace owns the state of the project being edited. Requests read a snapshot of
+// the settings and manifest, updates build a new manifest and swap it in so a
+// snapshot never changes under a request that is still using it
+type Workspace struct {
+	lock     sync.RWMutex
+	settings ProjectSettings
+	manifest Manifest
+	root     string
+	// watchFiles is set when the client lets us register file watchers
+	watchFiles bool
+	// documentChanges is set when the client can apply versioned document edits
+	// in a workspace edit, otherwise the edits go in the plain changes map
+	documentChanges bool
+	// renameFiles is set when the client can apply file renames in a workspace edit
+	renameFiles bool
+	// cycleMembers are the files of the models that were on a ref cycle when the
+	// diagnostics were last published
+	cycleMembers []string
+
+	// writeLock serialises updates so that two of them can't build on the same
+	// manifest and lose each other's changes
+	writeLock sync.Mutex
+
+	Documents *DocumentStore
+}
+
+func NewWorkspace() *Workspace {
+	return &Workspace{Documents: NewDocumentStore()}
+}
+
+// Snapshot returns the current settings and manifest, they must not be modified
+func (w *Workspace) Snapshot() (ProjectSettings, Manifest) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.settings, w.manifest
+}
+
+func (w *Workspace) Settings() ProjectSettings {
+	settings, _ := w.Snapshot()
+	return settings
+}
+
+func (w *Workspace) Manifest() Manifest {
+	_, manifest := w.Snapshot()
+	return manifest
+}
+
+func (w *Workspace) WatchFiles() bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.watchFiles
+}
+
+func (w *Workspace) SetWatchFiles(watchFiles bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.watchFiles = watchFiles
+}
+
+func (w *Workspace) DocumentChanges() bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.documentChanges
+}
+
+func (w *Workspace) SetDocumentChanges(documentChanges bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.documentChanges = documentChanges
+}
+
+func (w *Workspace) RenameFiles() bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.renameFiles
+}
+
+func (w *Workspace) SetRenameFiles(renameFiles bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.renameFiles = renameFiles
+}
+
+// Load reads the project at root and indexes it
+func (w *Workspace) Load(root string) error {
+	w.writeLock.Lock()
+	defer w.writeLock.Unlock()
+
+	settings, err := LoadSettings(root)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := settings.BuildManifest()
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.root, w.settings, w.manifest = root, settings, manifest
+	return nil
+}
+
+// Reload reads the project again, for changes that affect all of it like edits
+// to dbt_project.yml
+func (w *Workspace) Reload() error {
+	w.lock.RLock()
+	root := w.root
+	w.lock.RUnlock()
+
+	return w.Load(root)
+}
+
+// Update applies update to a copy of the manifest and publishes the copy once
+// update is done, readers keep the manifest they already had
+func (w *Workspace) Update(update func(manifest *Manifest, settings ProjectSettings)) Manifest {
+	w.writeLock.Lock()
+	defer w.writeLock.Unlock()
+
+	settings, current := w.Snapshot()
+	manifest := current.Clone()
+	update(&manifest, settings)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.manifest = manifest
+	return manifest
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// maxWorkspaceSymbols keeps the response small on large projects, the editor asks
+// again as the query gets longer
+const maxWorkspaceSymbols = 100
+
+func (w *Workspace) workspaceSymbolHandler(context *glsp.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	symbolLog := commonlog.GetLoggerf("%s.workspaceSymbols", lsName)
+	manifest := w.Manifest()
+
+	symbols := getWorkspaceSymbols(manifest, params.Query)
+	symbolLog.Infof("found %v symbols for %v", len(symbols), params.Query)
+	return symbols, nil
+}
+
+func getWorkspaceSymbols(manifest Manifest, query string) []protocol.SymbolInformation {
+	type match struct {
+		score  int
+		symbol protocol.SymbolInformation
+	}
+
+	matches := []match{}
+	add := func(key, name string, kind protocol.SymbolKind, location protocol.Location) {
+		score, ok := fuzzyScore(query, name)
+		if !ok {
+			return
+		}
+
+		symbol := protocol.SymbolInformation{Name: name, Kind: kind, Location: location}
+		if parts := strings.SplitN(key, ".", 3); len(parts) == 3 {
+			symbol.ContainerName = &parts[1]
+		}
+		matches = append(matches, match{score: score, symbol: symbol})
+	}
+
+	for key, node := range manifest.Nodes {
+		add(key, node.Name, getNodeSymbolKind(key), protocol.Location{URI: node.OriginalPath})
+	}
+
+	for key, macro := range manifest.Macros {
+		add(key, macro.Name, protocol.SymbolKindFunction, protocol.Location{URI: macro.OriginalPath, Range: macro.NameRange})
+	}
+
+	for key, source := range manifest.Sources {
+		name := fmt.Sprintf("%s.%s", source.SourceName, source.Name)
+		add(key, name, protocol.SymbolKindStruct, protocol.Location{URI: source.OriginalPath, Range: source.NameRange})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].symbol.Name < matches[j].symbol.Name
+	})
+
+	symbols := []protocol.SymbolInformation{}
+	for _, match := range matches {
+		if len(symbols) == maxWorkspaceSymbols {
+			break
+		}
+		symbols = append(symbols, match.symbol)
+	}
+	return symbols
+}
+
+func getNodeSymbolKind(key string) protocol.SymbolKind {
+	switch strings.SplitN(key, ".", 2)[0] {
+	case "seed":
+		return protocol.SymbolKindArray
+	case "snapshot":
+		return protocol.SymbolKindClass
+	}
+	return protocol.SymbolKindFile
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("fco", "fct_orders"); !ok {
+		t.Errorf("expected fco to match fct_orders")
+	}
+
+	if _, ok := fuzzyScore("ofc", "fct_orders"); ok {
+		t.Errorf("expected ofc not to match fct_orders")
+	}
+
+	exact, _ := fuzzyScore("orders", "orders")
+	boundary, _ := fuzzyScore("orders", "fct_orders")
+	scattered, _ := fuzzyScore("orders", "o_r_d_e_r_s_history")
+	if !(exact > boundary && boundary > scattered) {
+		t.Errorf("expected exact %v > boundary %v > scattered %v", exact, boundary, scattered)
+	}
+}
+
+func TestWorkspaceSymbols(t *testing.T) {
+	manifest := Manifest{
+		Nodes: map[string]Node{
+			"model.project.fct_orders":    {Name: "fct_orders", OriginalPath: "file:///project/models/fct_orders.sql"},
+			"model.project.stg_customers": {Name: "stg_customers", OriginalPath: "file:///project/models/stg_customers.sql"},
+			"seed.project.country_codes":  {Name: "country_codes", OriginalPath: "file:///project/seeds/country_codes.csv"},
+		},
+		Macros: map[string]Macro{
+			"macro.project.cents_to_dollars": {Name: "cents_to_dollars", OriginalPath: "file:///project/macros/cents.sql"},
+		},
+		Sources: map[string]Source{
+			"source.project.raw.orders": {Name: "orders", SourceName: "raw", OriginalPath: "file:///project/models/sources.yml"},
+		},
+	}
+
+	symbols := getWorkspaceSymbols(manifest, "orders")
+	if len(symbols) != 2 {
+		t.Fatalf("expected 2 symbols but got %+v", symbols)
+	}
+
+	if symbols[0].Name != "fct_orders" || *symbols[0].ContainerName != "project" {
+		t.Errorf("got wrong model symbol %+v", symbols[0])
+	}
+
+	if symbols[1].Name != "raw.orders" || symbols[1].Kind != protocol.SymbolKindStruct {
+		t.Errorf("got wrong source symbol %+v", symbols[1])
+	}
+
+	if symbols := getWorkspaceSymbols(manifest, "cc"); len(symbols) != 1 || symbols[0].Name != "country_codes" || symbols[0].Kind != protocol.SymbolKindArray {
+		t.Errorf("got wrong symbols for cc %+v", symbols)
+	}
+
+	if symbols := getWorkspaceSymbols(manifest, ""); len(symbols) != 5 {
+		t.Errorf("expected every symbol for an empty query but got %v", len(symbols))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWorkspaceCopyOnWrite(t *testing.T) {
+	workspace := NewWorkspace()
+	if err := workspace.Load("./tests/project"); err != nil {
+		t.Fatalf("could not load the workspace %v", err)
+	}
+
+	before := workspace.Manifest()
+	if _, ok := before.Nodes["model.jaffle_shop.orders"]; !ok {
+		t.Fatalf("expected the project to be indexed but got %v", before.Nodes)
+	}
+
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(2)
+		go func() {
+			defer wait.Done()
+			workspace.Update(func(manifest *Manifest, settings ProjectSettings) {
+				delete(manifest.Nodes, "model.jaffle_shop.orders")
+				removeReferences(manifest, "model.jaffle_shop.orders")
+			})
+		}()
+		go func() {
+			defer wait.Done()
+			settings, manifest := workspace.Snapshot()
+			for key := range manifest.Nodes {
+				_ = key + settings.Name
+			}
+		}()
+	}
+	wait.Wait()
+
+	if _, ok := workspace.Manifest().Nodes["model.jaffle_shop.orders"]; ok {
+		t.Errorf("expected the update to be published")
+	}
+
+	if _, ok := before.Nodes["model.jaffle_shop.orders"]; !ok || len(before.References["model.jaffle_shop.stg_orders"]) != 2 {
+		t.Errorf("an earlier snapshot should not change")
+	}
+}